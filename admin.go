@@ -0,0 +1,160 @@
+package sqsjfr
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AdminRequestTimeout defines a timeout of requests to admin API.
+var AdminRequestTimeout = 30 * time.Second
+
+type adminResponse struct {
+	Entry           string          `json:"entry,omitempty"`
+	DeduplicationID string          `json:"deduplication_id,omitempty"`
+	Message         json.RawMessage `json:"message,omitempty"`
+	Paused          *bool           `json:"paused,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+func writeAdminResponse(w http.ResponseWriter, code int, res *adminResponse) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+func (app *App) authorized(r *http.Request) bool {
+	token := app.option.AdminToken
+	if token == "" {
+		return false
+	}
+	given := r.Header.Get("Authorization")
+	if !strings.HasPrefix(given, "Bearer ") {
+		return false
+	}
+	given = strings.TrimPrefix(given, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// serveAdmin serves admin API.
+//
+//	POST /entries/{id}/run
+//	POST /entries/{id}/pause
+//	POST /entries/{id}/resume
+func (app *App) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if app.option.AdminToken == "" {
+		writeAdminResponse(w, http.StatusForbidden, &adminResponse{Error: "admin API is disabled"})
+		return
+	}
+	if !app.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sqsjfr"`)
+		writeAdminResponse(w, http.StatusUnauthorized, &adminResponse{Error: "unauthorized"})
+		return
+	}
+	p := strings.Split(strings.TrimPrefix(r.URL.Path, "/entries/"), "/")
+	if len(p) != 2 {
+		writeAdminResponse(w, http.StatusNotFound, &adminResponse{Error: "not found"})
+		return
+	}
+	id, action := p[0], p[1]
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAdminResponse(w, http.StatusMethodNotAllowed, &adminResponse{Error: "method not allowed"})
+		return
+	}
+	j := app.findJob(id)
+	if j == nil {
+		writeAdminResponse(w, http.StatusNotFound, &adminResponse{Error: "entry " + id + " is not found"})
+		return
+	}
+	switch action {
+	case "run":
+		code, res := app.runManually(j)
+		writeAdminResponse(w, code, res)
+	case "pause", "resume":
+		paused := action == "pause"
+		app.setPaused(j, paused)
+		writeAdminResponse(w, http.StatusOK, &adminResponse{Entry: j.String(), Paused: &paused})
+	default:
+		writeAdminResponse(w, http.StatusNotFound, &adminResponse{Error: "unknown action " + action})
+	}
+}
+
+// findJob finds a registered job by the name or the stable ID.
+func (app *App) findJob(id string) *Job {
+	if app.cron == nil {
+		return nil
+	}
+	var found *Job
+	for _, entry := range app.cron.Entries() {
+		j, ok := entry.Job.(*Job)
+		if !ok {
+			continue
+		}
+		if j.String() == id {
+			return j
+		}
+		if found == nil && j.StableID == id {
+			found = j // a named entry may have the same stable ID as an unnamed one
+		}
+	}
+	return found
+}
+
+// runManually sends a message of the job immediately.
+func (app *App) runManually(j *Job) (int, *adminResponse) {
+	mj := *j
+	mj.manual = true
+	msg, err := app.newMessage(&mj, time.Now())
+	if err != nil {
+		log.Printf("[warn] [entry:%s] %s", j, err)
+		return http.StatusInternalServerError, &adminResponse{Entry: j.String(), Error: err.Error()}
+	}
+	res := &adminResponse{
+		Entry:           j.String(),
+		DeduplicationID: msg.DeduplicationID(),
+		Message:         json.RawMessage(msg.String()),
+	}
+	log.Printf("[info] [entry:%s] invoke job manually %s", j, msg.String())
+	if err := app.send(msg); err != nil {
+		log.Printf("[error] [entry:%s] failed to send message: %s", j, err)
+		res.Error = err.Error()
+		return http.StatusInternalServerError, res
+	}
+	return http.StatusOK, res
+}
+
+// RequestAdmin requests the action (run, pause or resume) of the entry to admin API of a running sqsjfr.
+func RequestAdmin(adminURL, token, id, action string) ([]byte, error) {
+	u := strings.TrimSuffix(adminURL, "/") + "/entries/" + url.PathEscape(id) + "/" + action
+	req, err := http.NewRequest(http.MethodPost, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := &http.Client{Timeout: AdminRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var res adminResponse
+		if json.Unmarshal(b, &res) == nil && res.Error != "" {
+			return b, errors.New(res.Error)
+		}
+		return b, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return b, nil
+}
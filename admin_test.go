@@ -0,0 +1,163 @@
+package sqsjfr_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestAdminRun(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		AdminToken: "secret",
+	})
+	app.SetSQSEndpoint(ts.URL)
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	admin := httptest.NewServer(http.HandlerFunc(app.ServeAdmin))
+	defer admin.Close()
+
+	post := func(path, token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, admin.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := post("/entries/nightly-report/run", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d without token", resp.StatusCode)
+	}
+	if resp := post("/entries/nightly-report/run", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d with wrong token", resp.StatusCode)
+	}
+	if resp := post("/entries/not-found/run", "secret"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status %d for unknown entry", resp.StatusCode)
+	}
+
+	resp := post("/entries/nightly-report/run", "secret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	var res struct {
+		Entry           string         `json:"entry"`
+		DeduplicationID string         `json:"deduplication_id"`
+		Message         sqsjfr.Message `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Entry != "nightly-report" || !res.Message.Manual || res.Message.Command != "echo report" {
+		t.Errorf("unexpected response %#v", res)
+	}
+	if len(f.received) != 1 || f.received[0]["MessageDeduplicationId"] != res.DeduplicationID {
+		t.Errorf("unexpected received %v", f.received)
+	}
+
+	// deduplication ID of a manual invocation does not collide with the scheduled one
+	for _, entry := range app.Entries() {
+		j := entry.Job.(*sqsjfr.Job)
+		if j.Name != "nightly-report" {
+			continue
+		}
+		msg, err := app.NewMessage(j, time.Unix(res.Message.InvokedAt, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.DeduplicationID() == res.DeduplicationID {
+			t.Error("deduplication ID must not collide with the scheduled invocation")
+		}
+	}
+
+	if resp := post("/entries/nightly-report/run", "secret"); resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if len(f.received) != 2 || f.received[0]["MessageDeduplicationId"] == f.received[1]["MessageDeduplicationId"] {
+		t.Errorf("manual invocations must have unique deduplication IDs %v", f.received)
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{CrontabURL: "tests/crontab.names"})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/entries/nightly-report/run", nil)
+	app.ServeAdmin(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+}
+
+func TestAdminPauseResume(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		AdminToken: "secret",
+	})
+	app.SetSQSEndpoint(ts.URL)
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	admin := httptest.NewServer(http.HandlerFunc(app.ServeAdmin))
+	defer admin.Close()
+
+	var job *sqsjfr.Job
+	for _, entry := range app.Entries() {
+		if j := entry.Job.(*sqsjfr.Job); j.Name == "hourly-sync" {
+			job = j
+		}
+	}
+
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "pause"); err != nil {
+		t.Fatal(err)
+	}
+	job.Run()
+	if len(f.received) != 0 {
+		t.Errorf("paused entry must not be invoked %v", f.received)
+	}
+	if v := app.EntryViews()["hourly-sync"]; !v.Paused || v.Skipped != 1 {
+		t.Errorf("unexpected view %#v", v)
+	}
+
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "resume"); err != nil {
+		t.Fatal(err)
+	}
+	job.Run()
+	if len(f.received) != 1 {
+		t.Errorf("resumed entry must be invoked %v", f.received)
+	}
+	if v := app.EntryViews()["hourly-sync"]; v.Paused || v.Succeeded != 1 {
+		t.Errorf("unexpected view %#v", v)
+	}
+
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "wrong", "hourly-sync", "pause"); err == nil {
+		t.Error("must be failed with wrong token")
+	}
+
+	// a token without the Bearer scheme is not accepted
+	req, _ := http.NewRequest(http.MethodPost, admin.URL+"/entries/hourly-sync/pause", nil)
+	req.Header.Set("Authorization", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d without Bearer scheme", resp.StatusCode)
+	}
+}
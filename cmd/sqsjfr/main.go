@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -35,18 +37,67 @@ func main() {
 	}
 }
 
+// subcommands defines subcommands. Without a subcommand, sqsjfr runs as a daemon.
+var subcommands = map[string]bool{
+	"pause":    true,
+	"resume":   true,
+	"validate": true,
+	"lint":     true,
+	"next":     true,
+	"simulate": true,
+}
+
 func _main() error {
 	var opt sqsjfr.Option
-	var logLevel string
+	var logLevel, adminURL, format, from, to, output string
+	var count int
 
 	flag.StringVar(&opt.QueueURL, "queue-url", "", "SQS queue URL")
+	flag.StringVar(&opt.Destination, "destination", "", "destination URL (sqs://, sns://, eventbridge://, kinesis://, lambda://, http(s)://, file://, stdout://) (default SQS queue of -queue-url)")
 	flag.StringVar(&opt.MessageTemplate, "message-template", "", "SQS message template(JSON)")
+	flag.StringVar(&opt.EventSource, "event-source", sqsjfr.DefaultEventSource, "source of events (template) for EventBridge destination")
+	flag.StringVar(&opt.EventDetailType, "event-detail-type", sqsjfr.DefaultEventDetailType, "detail type of events (template) for EventBridge destination")
+	flag.StringVar(&opt.PartitionKey, "kinesis-partition-key", "", "partition key of records (template) for Kinesis destination (default entry name)")
+	flag.Var((*stringsFlag)(&opt.HTTPHeaders), "http-header", "header of HTTP requests (\"Name: value\") for HTTP destination, can be specified multiple times")
+	flag.DurationVar(&opt.HTTPTimeout, "http-timeout", sqsjfr.DefaultHTTPTimeout, "timeout of HTTP requests for HTTP destination")
+	flag.StringVar(&opt.HTTPSecret, "http-secret", "", "shared secret to sign HTTP request bodies for HTTP destination")
 	flag.StringVar(&logLevel, "log-level", "info", "log level")
 	flag.DurationVar(&opt.CheckInterval, "check-interval", time.Minute, "interval of checking for crontab modified")
 	flag.BoolVar(&opt.DryRun, "dry-run", false, "dry run")
 	flag.IntVar(&opt.StatsPort, "stats-port", sqsjfr.DefaultStatsServerPort, "stats HTTP server port")
+	flag.StringVar(&opt.Timezone, "timezone", "", "default timezone for schedules (default local)")
+	flag.BoolVar(&opt.Seconds, "seconds", false, "enable seconds field in crontab")
+	flag.BoolVar(&opt.ScopedEnv, "scoped-env", false, "environment variables apply only to the following entries")
+	flag.StringVar(&opt.StateURL, "state-url", "", "URL to persist the state (local path or s3://...)")
+	flag.StringVar(&opt.CatchUp, "catch-up", sqsjfr.CatchUpNone, "catch-up policy for missed invocations (none, once, all)")
+	flag.IntVar(&opt.CatchUpMax, "catch-up-max", 10, "maximum number of missed invocations to catch up by policy all")
+	flag.StringVar(&opt.SpoolDir, "spool-dir", "", "directory to spool messages which failed to send")
+	flag.DurationVar(&opt.SpoolMaxAge, "spool-max-age", time.Hour, "maximum age of spooled messages to retry")
+	flag.BoolVar(&opt.AllowStandardQueue, "allow-standard-queue", false, "allow SQS standard (non-FIFO) queues")
+	flag.StringVar(&opt.DedupURL, "dedup-url", "", "URL to claim deduplication IDs before sending among processes (dynamodb://{table} or local directory path)")
+	flag.DurationVar(&opt.DedupTTL, "dedup-ttl", sqsjfr.DefaultDedupTTL, "TTL of claimed deduplication IDs")
+	flag.StringVar(&opt.LeaseURL, "lease-url", "", "URL of the lease for leader election (dynamodb://{table}[/{name}] or local file path), only the leader runs the scheduler")
+	flag.DurationVar(&opt.LeaseDuration, "lease-duration", sqsjfr.DefaultLeaseDuration, "duration of the leader lease")
+	flag.IntVar(&opt.SendMaxAttempts, "send-max-attempts", sqsjfr.DefaultSendMaxAttempts, "maximum attempts to send a message")
+	flag.DurationVar(&opt.SendInitialBackoff, "send-initial-backoff", sqsjfr.DefaultSendInitialBackoff, "initial backoff to retry sending a message")
+	flag.DurationVar(&opt.SendMaxBackoff, "send-max-backoff", sqsjfr.DefaultSendMaxBackoff, "maximum backoff to retry sending a message")
+	flag.Float64Var(&opt.SendJitter, "send-jitter", sqsjfr.DefaultSendJitter, "jitter ratio of backoff (0.0-1.0)")
+	flag.StringVar(&opt.AdminToken, "admin-token", "", "bearer token for admin API (admin API is disabled when empty)")
+	flag.StringVar(&adminURL, "admin-url", fmt.Sprintf("http://localhost:%d", sqsjfr.DefaultStatsServerPort), "admin API URL of a running sqsjfr (for pause, resume)")
+	flag.StringVar(&format, "format", "text", "output format of validate, next (text, json)")
+	flag.IntVar(&count, "count", 5, "number of upcoming invocations for each entry (for next)")
+	flag.StringVar(&from, "from", "", "start time of the range (for next, simulate) (default now)")
+	flag.StringVar(&to, "to", "", "end time of the range (for next, simulate), all invocations in the range are shown")
+	flag.StringVar(&output, "output", "", "file to write simulated messages (for simulate) (default stdout)")
+	flag.Usage = usage
+
+	var command string
+	args := os.Args[1:]
+	if len(args) > 0 && subcommands[args[0]] {
+		command, args = args[0], args[1:]
+	}
 	flag.VisitAll(envToFlag)
-	flag.Parse()
+	flag.CommandLine.Parse(args)
 
 	filter := &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"debug", "info", "warn", "error"},
@@ -55,13 +106,35 @@ func _main() error {
 	}
 	log.SetOutput(filter)
 
-	args := flag.Args()
+	args = flag.Args()
+	switch command {
+	case "pause", "resume":
+		if len(args) != 1 {
+			return errors.New("entry name or ID is required")
+		}
+		b, err := sqsjfr.RequestAdmin(adminURL, opt.AdminToken, args[0], command)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(b)
+		return nil
+	}
+
 	if len(args) != 1 {
 		return errors.New("crontab is required")
 	}
 	opt.CrontabURL = args[0]
 	log.Printf("[debug] option:%#v", opt)
 
+	switch command {
+	case "validate", "lint":
+		return validate(&opt, format)
+	case "next":
+		return next(&opt, format, from, to, count)
+	case "simulate":
+		return simulate(&opt, from, to, output)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	signal.Notify(sigCh, trapSignals...)
@@ -76,7 +149,130 @@ func _main() error {
 	if err != nil {
 		return err
 	}
-	app.Run()
+	return app.Run()
+}
+
+func usage() {
+	fmt.Fprintln(flag.CommandLine.Output(), `Usage:
+  sqsjfr [options] (/path/to|http://...|s3://...)/crontab
+  sqsjfr pause [options] <entry name or ID>
+  sqsjfr resume [options] <entry name or ID>
+  sqsjfr validate [options] (/path/to|http://...|s3://...)/crontab
+  sqsjfr next [options] (/path/to|http://...|s3://...)/crontab
+  sqsjfr simulate [options] -to <time> (/path/to|http://...|s3://...)/crontab
+
+Options:`)
+	flag.PrintDefaults()
+}
+
+func validate(opt *sqsjfr.Option, format string) error {
+	diags, err := sqsjfr.Lint(opt)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		if diags == nil {
+			diags = []*sqsjfr.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			return err
+		}
+	case "text":
+		for _, d := range diags {
+			if d.Entry != "" {
+				fmt.Printf("%s:%d: %s: [entry:%s] %s\n", opt.CrontabURL, d.Line, d.Severity, d.Entry, d.Message)
+			} else {
+				fmt.Printf("%s:%d: %s: %s\n", opt.CrontabURL, d.Line, d.Severity, d.Message)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid format %s", format)
+	}
+	var errs int
+	for _, d := range diags {
+		if d.Severity == sqsjfr.SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d errors found in %s", errs, opt.CrontabURL)
+	}
+	log.Printf("[info] %s is valid", opt.CrontabURL)
+	return nil
+}
+
+func next(opt *sqsjfr.Option, format, from, to string, count int) error {
+	start, end, err := parseRange(opt, from, to)
+	if err != nil {
+		return err
+	}
+	previews, err := sqsjfr.Next(opt, start, end, count)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(previews)
+	case "text":
+		for _, p := range previews {
+			fmt.Printf("%s (%s) %s %s\n", p.Entry, p.Timezone, p.Spec, p.Command)
+			for _, f := range p.Fires {
+				fmt.Printf("  %s %s\n", f.Time.Format(time.RFC3339), f.Message)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid format %s", format)
+	}
+}
+
+func simulate(opt *sqsjfr.Option, from, to, output string) error {
+	if to == "" {
+		return errors.New("-to is required")
+	}
+	start, end, err := parseRange(opt, from, to)
+	if err != nil {
+		return err
+	}
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return sqsjfr.Simulate(opt, start, end, w)
+}
+
+func parseRange(opt *sqsjfr.Option, from, to string) (start, end time.Time, err error) {
+	start = time.Now()
+	if from != "" {
+		if start, err = opt.ParseTime(from); err != nil {
+			return
+		}
+	}
+	if to != "" {
+		end, err = opt.ParseTime(to)
+	}
+	return
+}
+
+// stringsFlag is a flag which can be specified multiple times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
 	return nil
 }
 
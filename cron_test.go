@@ -1,13 +1,16 @@
 package sqsjfr_test
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kayac/sqsjfr"
 	"github.com/robfig/cron/v3"
@@ -35,7 +38,7 @@ func TestReadCrontab(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	c, envs, digest, err := sqsjfr.ReadCrontab(f, newJob)
+	c, envs, digest, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{}, newJob)
 	if err != nil {
 		t.Error(err)
 	}
@@ -73,7 +76,7 @@ func TestReadCrontabFail(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	c, _, _, err := sqsjfr.ReadCrontab(f, newJob)
+	c, _, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{}, newJob)
 	t.Log(err)
 	if err == nil {
 		t.Error("must be failed")
@@ -90,7 +93,7 @@ func TestReadCrontabFailEnv(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	c, envs, _, err := sqsjfr.ReadCrontab(f, newJob)
+	c, envs, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{}, newJob)
 	t.Log(err)
 	if err == nil {
 		t.Error("must be failed")
@@ -118,7 +121,7 @@ func TestReadCrontabHTTP(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	c, envs, digest, err := sqsjfr.ReadCrontab(f, newJob)
+	c, envs, digest, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{}, newJob)
 	if err != nil {
 		t.Error(err)
 	}
@@ -148,3 +151,286 @@ func TestReadCrontabHTTP(t *testing.T) {
 		t.Error("unexpected BAR", envs["BAR"])
 	}
 }
+
+func TestReadCrontabTimezone(t *testing.T) {
+	f, err := os.Open("tests/crontab.tz")
+	if err != nil {
+		t.Error(err)
+	}
+	c, _, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{Timezone: "UTC"}, newJob)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []string{"UTC", "Asia/Tokyo", "America/New_York"}
+	entries := c.Entries()
+	if len(entries) != len(expected) {
+		t.Fatalf("unexpected loaded entries len %d", len(entries))
+	}
+	for i, entry := range entries {
+		s, ok := entry.Schedule.(*cron.SpecSchedule)
+		if !ok {
+			t.Errorf("unexpected schedule type %T", entry.Schedule)
+			continue
+		}
+		if loc := s.Location.String(); loc != expected[i] {
+			t.Errorf("unexpected location of entry %d: %s", i, loc)
+		}
+	}
+}
+
+func TestReadCrontabBadTimezone(t *testing.T) {
+	r := strings.NewReader("CRON_TZ=Asia/Nowhere\n* * * * * date\n")
+	_, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{}, newJob)
+	t.Log(err)
+	if err == nil {
+		t.Error("must be failed")
+	}
+}
+
+func TestReadCrontabDescriptors(t *testing.T) {
+	testResults = testResults[0:0]
+
+	f, err := os.Open("tests/crontab.descriptors")
+	if err != nil {
+		t.Error(err)
+	}
+	c, _, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{}, newJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range c.Entries() {
+		entry.Job.Run()
+	}
+	expected := []string{
+		"result of echo hourly",
+		"result of echo daily",
+		"result of echo every 5 minutes",
+		"result of echo at nine",
+	}
+	if len(testResults) != len(expected) {
+		t.Fatalf("unexpected entries len %d", len(testResults))
+	}
+	for i, r := range testResults {
+		if r != expected[i] {
+			t.Errorf("unexpected test result[%d] %s", i, r)
+		}
+	}
+}
+
+func TestReadCrontabSeconds(t *testing.T) {
+	f, err := os.Open("tests/crontab.seconds")
+	if err != nil {
+		t.Error(err)
+	}
+	c, _, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{Seconds: true}, newJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Entries()) != 3 {
+		t.Errorf("unexpected loaded entries len %d", len(c.Entries()))
+	}
+	now := time.Date(2020, 10, 7, 11, 22, 33, 0, time.Local)
+	next := c.Entries()[0].Schedule.Next(now)
+	if next.Second() != 30 || next.Minute() != 23 {
+		t.Errorf("unexpected next time %s", next)
+	}
+}
+
+func TestReadCrontabSubMinuteWithoutSeconds(t *testing.T) {
+	r := strings.NewReader("@every 30s date\n")
+	_, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{}, newJob)
+	t.Log(err)
+	if err == nil {
+		t.Error("must be failed")
+	}
+}
+
+func TestReadCrontabScopedEnv(t *testing.T) {
+	var jobs []*sqsjfr.Job
+	fn := func(command string) cron.Job {
+		j := &sqsjfr.Job{Command: command}
+		jobs = append(jobs, j)
+		return j
+	}
+	f, err := os.Open("tests/crontab.scoped")
+	if err != nil {
+		t.Error(err)
+	}
+	_, envs, _, err := sqsjfr.ReadCrontab(f, &sqsjfr.Option{ScopedEnv: true}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("unexpected jobs len %d", len(jobs))
+	}
+	if e := jobs[0].Env; e["RUNNER"] != "/usr/local/bin/runner" || e["APP_ENV"] != "production" {
+		t.Errorf("unexpected envs of jobs[0] %s", e)
+	}
+	if e := jobs[1].Env; e["RUNNER"] != "/usr/local/bin/runner" || e["APP_ENV"] != "staging" {
+		t.Errorf("unexpected envs of jobs[1] %s", e)
+	}
+	if envs["RUNNER"] != "/usr/local/bin/other-runner" || envs["APP_ENV"] != "staging" {
+		t.Errorf("unexpected envs %s", envs)
+	}
+}
+
+func TestReadCrontabEntryOptions(t *testing.T) {
+	var jobs []*sqsjfr.Job
+	fn := func(command string) cron.Job {
+		j := &sqsjfr.Job{Command: command}
+		jobs = append(jobs, j)
+		return j
+	}
+	f, err := os.Open("tests/crontab.options")
+	if err != nil {
+		t.Error(err)
+	}
+	opt := &sqsjfr.Option{
+		QueueURL: "https://sqs.ap-northeast-1.amazonaws.com/123456789012/default.fifo",
+	}
+	_, _, _, err = sqsjfr.ReadCrontab(f, opt, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 4 {
+		t.Fatalf("unexpected jobs len %d", len(jobs))
+	}
+	if j := jobs[0]; j.Command != "echo heavy" ||
+		j.QueueURL != "https://sqs.ap-northeast-1.amazonaws.com/123456789012/heavy.fifo" ||
+		j.MessageGroupID != "billing" ||
+		j.Delay != 30*time.Second {
+		t.Errorf("unexpected jobs[0] %#v", j)
+	}
+	if j := jobs[1]; j.Command != "echo template" || j.MessageTemplate != "tests/message.template" {
+		t.Errorf("unexpected jobs[1] %#v", j)
+	}
+	if j := jobs[2]; j.QueueURL != "https://sqs.us-east-1.amazonaws.com/123456789012/other.fifo" {
+		t.Errorf("unexpected jobs[2] %#v", j)
+	}
+	if j := jobs[3]; j.QueueURL != "" || j.MessageGroupID != "" || j.Delay != 0 || j.MessageTemplate != "" {
+		t.Errorf("unexpected jobs[3] %#v", j)
+	}
+}
+
+func TestReadCrontabBadEntryOptions(t *testing.T) {
+	opt := &sqsjfr.Option{
+		QueueURL: "https://sqs.ap-northeast-1.amazonaws.com/123456789012/default.fifo",
+	}
+	for _, line := range []string{
+		"[queue=standard] * * * * * date",
+		"[delay=1h] * * * * * date",
+		"[delay=1500ms] * * * * * date",
+		"[foo=bar] * * * * * date",
+		"[group=billing * * * * * date",
+		`[detail-type="Scheduled Job] * * * * * date`,
+		`[detail-type="Scheduled\"] * * * * * date`,
+		`[group=""] * * * * * date`,
+		"[template=tests/notfound.json] * * * * * date",
+	} {
+		_, _, _, err := sqsjfr.ReadCrontab(strings.NewReader(line), opt, newJob)
+		t.Log(err)
+		if err == nil {
+			t.Errorf("must be failed: %s", line)
+		}
+	}
+}
+
+func TestReadCrontabNames(t *testing.T) {
+	var jobs []*sqsjfr.Job
+	fn := func(command string) cron.Job {
+		j := &sqsjfr.Job{Command: command}
+		jobs = append(jobs, j)
+		return j
+	}
+	f, err := os.Open("tests/crontab.names")
+	if err != nil {
+		t.Error(err)
+	}
+	_, _, _, err = sqsjfr.ReadCrontab(f, &sqsjfr.Option{Timezone: "UTC"}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("unexpected jobs len %d", len(jobs))
+	}
+	if jobs[0].String() != "nightly-report" || jobs[1].String() != "hourly-sync" {
+		t.Errorf("unexpected names %s %s", jobs[0], jobs[1])
+	}
+	if jobs[2].Name != "" || jobs[2].String() != jobs[2].StableID || len(jobs[2].StableID) != 16 {
+		t.Errorf("unexpected unnamed job %#v", jobs[2])
+	}
+
+	// stable IDs are not changed by reordering and adding entries
+	var ids []string
+	for _, j := range jobs {
+		ids = append(ids, j.StableID)
+	}
+	jobs = jobs[0:0]
+	r := strings.NewReader("* * * * * echo added\n* * * * * echo unnamed\n@hourly echo sync\n0 3 * * * echo report\n")
+	if _, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{Timezone: "UTC"}, fn); err != nil {
+		t.Fatal(err)
+	}
+	if jobs[1].StableID != ids[2] || jobs[2].StableID != ids[1] || jobs[3].StableID != ids[0] {
+		t.Errorf("stable IDs are changed %v", ids)
+	}
+}
+
+func TestReadCrontabDuplicatedNames(t *testing.T) {
+	r := strings.NewReader("# name: foo\n* * * * * date\n[name=foo] * * * * * date\n")
+	_, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{}, newJob)
+	t.Log(err)
+	if err == nil {
+		t.Error("must be failed")
+	}
+}
+
+func TestReadCrontabDuplicatedEntries(t *testing.T) {
+	r := strings.NewReader("* * * * * date\n* * * * * date\n")
+	_, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{}, newJob)
+	t.Log(err)
+	if err == nil || !strings.Contains(err.Error(), "line 2, the same entry as line 1 (name this entry)") {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, crontab := range []string{
+		"[name=foo] * * * * * date\n[name=bar] * * * * * date\n",
+		"[name=foo] * * * * * date\n* * * * * date\n",
+		"* * * * * date\n[name=foo] * * * * * date\n",
+	} {
+		if _, _, _, err := sqsjfr.ReadCrontab(strings.NewReader(crontab), &sqsjfr.Option{}, newJob); err != nil {
+			t.Errorf("%s: %s", crontab, err)
+		}
+	}
+}
+
+func TestDelayCanceledOnShutdown(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.options",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/default.fifo",
+	})
+	app.SetContext(ctx)
+	app.SetSQSEndpoint(ts.URL)
+	app.SetStateURL(filepath.Join(t.TempDir(), "state.json"))
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	j := app.Entries()[0].Job.(*sqsjfr.Job)
+	if j.Delay != 30*time.Second {
+		t.Fatalf("unexpected delay %s", j.Delay)
+	}
+	cancel()
+	start := time.Now()
+	j.Invoke(start)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("delay must be canceled on shutdown, but waited %s", d)
+	}
+	if len(f.received) != 0 {
+		t.Errorf("delayed message must not be sent on shutdown %v", f.received)
+	}
+	if last, ok := app.State().LastFired(j.String()); ok {
+		t.Errorf("discarded invocation must not be recorded as fired %s", last)
+	}
+}
@@ -0,0 +1,187 @@
+package sqsjfr
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// DefaultDedupTTL defines how long claimed deduplication IDs are kept.
+const DefaultDedupTTL = 24 * time.Hour
+
+// DedupPurgeInterval defines an interval to purge expired lock files.
+var DedupPurgeInterval = time.Minute
+
+const dedupLockSuffix = ".lock"
+
+// deduplicator claims deduplication IDs of messages, so that only one of multiple processes sends a message.
+type deduplicator interface {
+	// Claim records the ID and reports whether the ID was not claimed yet.
+	Claim(ctx context.Context, id string) (bool, error)
+	// Release removes the ID to allow sending the message again.
+	Release(ctx context.Context, id string) error
+}
+
+// parseDedupURL parses dynamodb://{table} or a local directory path.
+func parseDedupURL(s string) (scheme, target string, err error) {
+	if table := strings.TrimPrefix(s, "dynamodb://"); table != s {
+		if table == "" || strings.Contains(table, "/") {
+			return "", "", errors.Errorf("invalid dedup URL %s: table name is required", s)
+		}
+		return "dynamodb", table, nil
+	}
+	if i := strings.Index(s, "://"); i > 0 {
+		return "", "", errors.Errorf("dedup URL scheme %s is not supported", s[:i])
+	}
+	return "file", s, nil
+}
+
+func newDeduplicator(s string, ttl time.Duration, sess *session.Session) (deduplicator, error) {
+	scheme, target, err := parseDedupURL(s)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = DefaultDedupTTL
+	}
+	if scheme == "dynamodb" {
+		return &dynamoDBDeduplicator{svc: dynamodb.New(sess), table: target, ttl: ttl}, nil
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create dedup directory %s", target)
+	}
+	return &fileDeduplicator{dir: target, ttl: ttl}, nil
+}
+
+// dynamoDBDeduplicator claims IDs by conditional writes to a DynamoDB table.
+// The table must have a partition key "id" (string). Enable TTL on the attribute "expires_at" to expire items.
+type dynamoDBDeduplicator struct {
+	svc   *dynamodb.DynamoDB
+	table string
+	ttl   time.Duration
+}
+
+func (d *dynamoDBDeduplicator) Claim(ctx context.Context, id string) (bool, error) {
+	expiresAt := time.Now().Add(d.ttl).Unix()
+	_, err := d.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":         {S: aws.String(id)},
+			"expires_at": {N: aws.String(strconv.FormatInt(expiresAt, 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (d *dynamoDBDeduplicator) Release(ctx context.Context, id string) error {
+	_, err := d.svc.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+	})
+	return err
+}
+
+// fileDeduplicator claims IDs by creating lock files exclusively in a directory shared by processes on a host.
+type fileDeduplicator struct {
+	dir string
+	ttl time.Duration
+
+	mu       sync.Mutex
+	purgedAt time.Time
+}
+
+func (d *fileDeduplicator) path(id string) string {
+	return filepath.Join(d.dir, id+dedupLockSuffix)
+}
+
+func (d *fileDeduplicator) Claim(ctx context.Context, id string) (bool, error) {
+	d.mu.Lock()
+	if time.Since(d.purgedAt) >= DedupPurgeInterval {
+		d.purgedAt = time.Now()
+		go d.purge(d.purgedAt)
+	}
+	d.mu.Unlock()
+
+	f, err := os.OpenFile(d.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, f.Close()
+}
+
+func (d *fileDeduplicator) Release(ctx context.Context, id string) error {
+	if err := os.Remove(d.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// purge removes lock files older than the TTL.
+func (d *fileDeduplicator) purge(now time.Time) {
+	files, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		log.Printf("[warn] failed to read dedup directory %s: %s", d.dir, err)
+		return
+	}
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !strings.HasSuffix(f.Name(), dedupLockSuffix) || now.Sub(f.ModTime()) < d.ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			log.Printf("[warn] failed to remove expired lock file %s: %s", f.Name(), err)
+		}
+	}
+}
+
+// claim claims the deduplication ID of the message, and reports whether the message should be sent.
+// When the deduplicator is unavailable, the message is sent anyway to keep delivering at least once.
+func (app *App) claim(msg *Message) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), app.option.sendTimeout())
+	defer cancel()
+	id := msg.DeduplicationID()
+	ok, err := app.dedup.Claim(ctx, id)
+	if err != nil {
+		log.Printf("[warn] [entry:%s] failed to claim deduplication ID %s, sending anyway: %s", msg.entry(), id, err)
+		return true
+	}
+	if !ok {
+		log.Printf("[info] [entry:%s] message %s is already claimed by another process", msg.entry(), id)
+		atomic.AddInt64(&app.stats.Invocations.Deduplicated, 1)
+		atomic.AddInt64(&app.stats.entry(msg.entry()).Deduplicated, 1)
+	}
+	return ok
+}
+
+// release releases the deduplication ID of the message which failed to send, so that another process can send it.
+func (app *App) release(msg *Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), app.option.sendTimeout())
+	defer cancel()
+	if err := app.dedup.Release(ctx, msg.DeduplicationID()); err != nil {
+		log.Printf("[warn] [entry:%s] failed to release deduplication ID %s: %s", msg.entry(), msg.DeduplicationID(), err)
+	}
+}
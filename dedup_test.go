@@ -0,0 +1,98 @@
+package sqsjfr_test
+
+import (
+	"testing"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestSendStandardQueueWithDedup(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	dir := t.TempDir()
+
+	msg := newTestMessage(t, ts.URL)
+	msg.QueueURL = ts.URL + "/123456789012/test"
+	var apps []*sqsjfr.App
+	for i := 0; i < 2; i++ {
+		app := newRetryTestApp(ts.URL)
+		if err := app.SetDedupURL(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Send(msg); err != nil {
+			t.Error(err)
+		}
+		apps = append(apps, app)
+	}
+	if len(f.received) != 1 {
+		t.Fatalf("unexpected received len %d", len(f.received))
+	}
+	r := f.received[0]
+	if _, ok := r["MessageDeduplicationId"]; ok {
+		t.Errorf("standard queue must not have MessageDeduplicationId %v", r)
+	}
+	if _, ok := r["MessageGroupId"]; ok {
+		t.Errorf("standard queue must not have MessageGroupId %v", r)
+	}
+	if s := apps[0].Stats(); s.Invocations.Succeeded != 1 || s.Invocations.Deduplicated != 0 {
+		t.Errorf("unexpected stats %#v", s.Invocations)
+	}
+	if s := apps[1].Stats(); s.Invocations.Succeeded != 0 || s.Invocations.Deduplicated != 1 {
+		t.Errorf("unexpected stats %#v", s.Invocations)
+	}
+	if e := apps[1].Stats().EntryStats(msg.EntryKey); e.Deduplicated != 1 {
+		t.Errorf("unexpected entry stats %#v", e)
+	}
+}
+
+func TestSendDedupReleaseOnFailure(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"AccessDenied"}
+	dir := t.TempDir()
+
+	msg := newTestMessage(t, ts.URL)
+	msg.QueueURL = ts.URL + "/123456789012/test"
+	app1 := newRetryTestApp(ts.URL)
+	app2 := newRetryTestApp(ts.URL)
+	for _, app := range []*sqsjfr.App{app1, app2} {
+		if err := app.SetDedupURL(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app1.Send(msg); err == nil {
+		t.Error("must be failed")
+	}
+	// another process can send the message released by the failure
+	if err := app2.Send(msg); err != nil {
+		t.Error(err)
+	}
+	if len(f.received) != 1 {
+		t.Errorf("unexpected received len %d", len(f.received))
+	}
+	if s := app2.Stats(); s.Invocations.Succeeded != 1 || s.Invocations.Deduplicated != 0 {
+		t.Errorf("unexpected stats %#v", s.Invocations)
+	}
+}
+
+func TestValidateStandardQueue(t *testing.T) {
+	queueURL := "https://sqs.ap-northeast-1.amazonaws.com/123456789012/standard"
+	opt := &sqsjfr.Option{QueueURL: queueURL}
+	if err := opt.Validate(); err == nil {
+		t.Error("standard queue must be invalid without AllowStandardQueue")
+	}
+	opt = &sqsjfr.Option{QueueURL: queueURL, AllowStandardQueue: true, DedupURL: "dynamodb://sqsjfr-dedup"}
+	if err := opt.Validate(); err != nil {
+		t.Error(err)
+	}
+	opt = &sqsjfr.Option{Destination: "sqs://sqs.ap-northeast-1.amazonaws.com/123456789012/standard", AllowStandardQueue: true}
+	if err := opt.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, u := range []string{"dynamodb://", "dynamodb://table/key", "redis://localhost:6379"} {
+		opt := &sqsjfr.Option{QueueURL: queueURL, AllowStandardQueue: true, DedupURL: u}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("dedup URL %s must be invalid", u)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package sqsjfr
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxDelay defines the maximum delay of an entry, same as the limit of SQS DelaySeconds.
+const MaxDelay = 15 * time.Minute
+
+// entryOptions represents options of an entry in crontab.
+// [name=heavy queue=heavy.fifo group=billing delay=30s template=heavy.json] 0 * * * * command
+// [source=batch detail-type={{.EntryName}}] 0 * * * * command
+// [detail-type="Scheduled {{.EntryName}}"] 0 * * * * command
+// [partition-key={{.Command}}] 0 * * * * command
+// [function=heavy:live] 0 * * * * command
+type entryOptions struct {
+	Name            string
+	QueueURL        string
+	MessageGroupID  string
+	Delay           time.Duration
+	MessageTemplate string
+	EventSource     string
+	EventDetailType string
+	PartitionKey    string
+	FunctionARN     string
+}
+
+// splitEntryOptions splits a line into an options block and the rest.
+func splitEntryOptions(line string) (string, string, error) {
+	if !strings.HasPrefix(line, "[") {
+		return "", line, nil
+	}
+	var i int
+	err := scanOptions(line, func(n int, c byte) bool {
+		i = n
+		return c == ']'
+	})
+	if err != nil || line[i] != ']' {
+		return "", "", errors.New("options block is not closed")
+	}
+	return line[1:i], reTrimPrefix.ReplaceAllString(line[i+1:], ""), nil
+}
+
+// scanOptions calls fn for each byte of s outside of double quoted values until fn returns true.
+func scanOptions(s string, fn func(int, byte) bool) error {
+	quoted, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && fn(i, c):
+			return nil
+		}
+	}
+	if quoted {
+		return errors.New("quote is not closed")
+	}
+	return nil
+}
+
+// splitOptionFields splits an options block by spaces.
+// A value may be double quoted to contain spaces (e.g. detail-type="Scheduled {{.EntryName}}").
+func splitOptionFields(s string) ([]string, error) {
+	var fields []string
+	start := 0
+	err := scanOptions(s+" ", func(i int, c byte) bool {
+		if c == ' ' || c == '\t' {
+			if i > start {
+				fields = append(fields, s[start:i])
+			}
+			start = i + 1
+		}
+		return false
+	})
+	return fields, err
+}
+
+func parseEntryOptions(s string, opt *Option) (*entryOptions, error) {
+	eo := &entryOptions{}
+	fields, err := splitOptionFields(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range fields {
+		p := strings.SplitN(kv, "=", 2)
+		if len(p) != 2 || p[1] == "" {
+			return nil, fmt.Errorf("invalid option %s", kv)
+		}
+		key, value := p[0], p[1]
+		if strings.HasPrefix(value, `"`) {
+			v, err := strconv.Unquote(value)
+			if err != nil || v == "" {
+				return nil, fmt.Errorf("invalid option %s", kv)
+			}
+			value = v
+		}
+		switch key {
+		case "name":
+			eo.Name = value
+		case "queue":
+			if opt.destinationScheme() != "sqs" {
+				return nil, fmt.Errorf("option %s is available only for SQS destination", key)
+			}
+			u, err := resolveQueueURL(opt.queueURL(), value, !opt.AllowStandardQueue)
+			if err != nil {
+				return nil, err
+			}
+			eo.QueueURL = u
+		case "group":
+			eo.MessageGroupID = value
+		case "delay":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid delay %s", value)
+			}
+			if d < 0 || d > MaxDelay {
+				return nil, fmt.Errorf("delay must be between 0 and %s", MaxDelay)
+			}
+			if d%time.Second != 0 {
+				return nil, fmt.Errorf("delay must be a multiple of a second")
+			}
+			eo.Delay = d
+		case "template":
+			if _, err := newMessage(&Job{Command: `echo "hello world!"`}, value, time.Now(), opt.precision(), Environments{}); err != nil {
+				return nil, err
+			}
+			eo.MessageTemplate = value
+		case "source", "detail-type":
+			if opt.destinationScheme() != "eventbridge" {
+				return nil, fmt.Errorf("option %s is available only for EventBridge destination", key)
+			}
+			if err := checkTemplateField(key, value); err != nil {
+				return nil, err
+			}
+			if key == "source" {
+				eo.EventSource = value
+			} else {
+				eo.EventDetailType = value
+			}
+		case "partition-key":
+			if opt.destinationScheme() != "kinesis" {
+				return nil, fmt.Errorf("option %s is available only for Kinesis destination", key)
+			}
+			if err := checkTemplateField(key, value); err != nil {
+				return nil, err
+			}
+			eo.PartitionKey = value
+		case "function":
+			if opt.destinationScheme() != "lambda" {
+				return nil, fmt.Errorf("option %s is available only for Lambda destination", key)
+			}
+			a, err := resolveFunctionARN(strings.TrimPrefix(opt.Destination, "lambda://"), value)
+			if err != nil {
+				return nil, err
+			}
+			eo.FunctionARN = a
+		default:
+			return nil, fmt.Errorf("unknown option %s", key)
+		}
+	}
+	return eo, nil
+}
+
+// resolveQueueURL resolves a queue name or a queue URL based on the default queue URL.
+func resolveQueueURL(base, s string, requireFIFO bool) (string, error) {
+	if !strings.Contains(s, "://") {
+		u, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		u.Path = path.Join(path.Dir(u.Path), s)
+		s = u.String()
+	}
+	if err := validateQueueURL(s, requireFIFO); err != nil {
+		return "", err
+	}
+	return s, nil
+}
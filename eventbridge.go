@@ -0,0 +1,91 @@
+package sqsjfr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/pkg/errors"
+)
+
+// Default values of events sent to EventBridge.
+const (
+	DefaultEventSource     = "sqsjfr"
+	DefaultEventDetailType = "Scheduled Job"
+)
+
+// eventBridgeSender puts messages as events to an EventBridge event bus.
+type eventBridgeSender struct {
+	svc    *eventbridge.EventBridge
+	busARN string
+}
+
+func newEventBridgeSender(sess *session.Session, busARN string) (*eventBridgeSender, error) {
+	a, err := parseEventBusARN(busARN)
+	if err != nil {
+		return nil, err
+	}
+	return &eventBridgeSender{
+		svc:    eventbridge.New(sess, aws.NewConfig().WithRegion(a.Region).WithMaxRetries(0)), // retried by sendWithRetry
+		busARN: busARN,
+	}, nil
+}
+
+func (s *eventBridgeSender) Send(ctx context.Context, msg *Message) error {
+	in := &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				EventBusName: aws.String(s.busARN),
+				Source:       aws.String(msg.EventSource),
+				DetailType:   aws.String(msg.EventDetailType),
+				Detail:       aws.String(msg.String()),
+				Time:         aws.Time(time.Unix(msg.InvokedAt, 0)),
+			},
+		},
+	}
+	log.Println("[debug] putting event:", in.String())
+	out, err := s.svc.PutEventsWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.Entries) > 0 {
+		e := out.Entries[0]
+		return fmt.Errorf("failed to put event: %s %s", aws.StringValue(e.ErrorCode), aws.StringValue(e.ErrorMessage))
+	}
+	if len(out.Entries) > 0 {
+		log.Println("[debug] put eventID:", aws.StringValue(out.Entries[0].EventId))
+	}
+	return nil
+}
+
+// arn:aws:events:ap-northeast-1:123456789012:event-bus/bus_name
+func parseEventBusARN(s string) (arn.ARN, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return a, errors.Wrapf(err, "invalid event bus ARN:%s", s)
+	}
+	if a.Service != "events" || a.Region == "" || !strings.HasPrefix(a.Resource, "event-bus/") || a.Resource == "event-bus/" {
+		return a, errors.Errorf("invalid event bus ARN:%s", s)
+	}
+	return a, nil
+}
+
+// setEventFields sets Source and DetailType of the event rendered by templates of the entry or the option.
+func setEventFields(opt *Option, j *Job, msg *Message) error {
+	source := firstNonEmpty(j.EventSource, opt.EventSource, DefaultEventSource)
+	detailType := firstNonEmpty(j.EventDetailType, opt.EventDetailType, DefaultEventDetailType)
+	var err error
+	if msg.EventSource, err = renderTemplateField("source", source, msg); err != nil {
+		return err
+	}
+	if msg.EventDetailType, err = renderTemplateField("detail-type", detailType, msg); err != nil {
+		return err
+	}
+	return nil
+}
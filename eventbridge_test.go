@@ -0,0 +1,137 @@
+package sqsjfr_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+	"github.com/robfig/cron/v3"
+)
+
+const testEventBusARN = "arn:aws:events:ap-northeast-1:123456789012:event-bus/test"
+
+type putEventsEntry struct {
+	EventBusName string
+	Source       string
+	DetailType   string
+	Detail       string
+}
+
+func TestSendEventBridge(t *testing.T) {
+	var received []putEventsEntry
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "AWSEvents.PutEvents" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var in struct{ Entries []putEventsEntry }
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, in.Entries...)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"FailedEntryCount":0,"Entries":[{"EventId":"event-1"}]}`))
+	}))
+	defer ts.Close()
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL:      "tests/crontab.events",
+		Destination:     "eventbridge://" + testEventBusARN,
+		EventDetailType: "{{.Command}}",
+		Timezone:        "UTC",
+	})
+	app.SetSQSEndpoint(ts.URL)
+	if err := app.SetDestination("eventbridge://" + testEventBusARN); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range app.Entries() {
+		msg, err := app.NewMessage(entry.Job.(*sqsjfr.Job), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(received) != 2 {
+		t.Fatalf("unexpected received %d", len(received))
+	}
+	expected := [][]string{
+		{"batch", "job.report", "echo report"},
+		{sqsjfr.DefaultEventSource, "echo default", "echo default"},
+	}
+	for i, e := range received {
+		var detail sqsjfr.Message
+		if err := json.Unmarshal([]byte(e.Detail), &detail); err != nil {
+			t.Fatal(err)
+		}
+		if e.EventBusName != testEventBusARN || e.Source != expected[i][0] || e.DetailType != expected[i][1] || detail.Command != expected[i][2] {
+			t.Errorf("unexpected event %#v", e)
+		}
+	}
+}
+
+func TestValidateEventBridge(t *testing.T) {
+	for _, dest := range []string{
+		"eventbridge://arn:aws:events:ap-northeast-1:123456789012:rule/test",
+		"eventbridge://arn:aws:sns:ap-northeast-1:123456789012:event-bus/test",
+		"eventbridge://test",
+	} {
+		opt := &sqsjfr.Option{Destination: dest}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("destination %s must be invalid", dest)
+		}
+	}
+	opt := &sqsjfr.Option{Destination: "eventbridge://" + testEventBusARN, EventSource: "{{.Unknown}}"}
+	if err := opt.Validate(); err == nil {
+		t.Error("invalid source template must be an error")
+	}
+	for _, detailType := range []string{"sqsjfr.{{.Command}}", "{{.Env.APP_ENV}}", "{{.EntryName}}"} {
+		opt = &sqsjfr.Option{Destination: "eventbridge://" + testEventBusARN, EventDetailType: detailType}
+		if err := opt.Validate(); err != nil {
+			t.Errorf("detail-type %s must be valid: %s", detailType, err)
+		}
+	}
+}
+
+func TestEventBridgeEntryOptionTemplates(t *testing.T) {
+	opt := &sqsjfr.Option{Destination: "eventbridge://" + testEventBusARN}
+	var jobs []*sqsjfr.Job
+	fn := func(command string) cron.Job {
+		j := &sqsjfr.Job{Command: command, Location: time.UTC}
+		jobs = append(jobs, j)
+		return j
+	}
+	crontab := strings.Join([]string{
+		"APP_ENV=production",
+		"[name=report detail-type={{.EntryName}}] 0 3 * * * echo report",
+		`[name=sync source="batch {{.Env.APP_ENV}}" detail-type="Scheduled [{{.EntryName}}]"] 0 4 * * * echo sync`,
+	}, "\n")
+	_, envs, _, err := sqsjfr.ReadCrontab(strings.NewReader(crontab), opt, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := sqsjfr.NewTestApp(opt)
+	for i, expected := range [][2]string{
+		{"sqsjfr", "report"},
+		{"batch production", "Scheduled [sync]"},
+	} {
+		j := jobs[i]
+		j.Env = envs
+		msg, err := app.NewMessage(j, time.Unix(60, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.EventSource != expected[0] || msg.EventDetailType != expected[1] {
+			t.Errorf("unexpected source %q detail-type %q", msg.EventSource, msg.EventDetailType)
+		}
+	}
+}
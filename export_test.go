@@ -1,7 +1,187 @@
 package sqsjfr
 
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/robfig/cron/v3"
+)
+
 var (
-	NewMessage  = newMessage
-	ReadCrontab = readCrontab
-	ReadHTTP    = readHTTP
+	NewMessage    = newMessage
+	ReadCrontab   = readCrontab
+	ReadHTTP      = readHTTP
+	MissedTimes   = missedTimes
+	NewStateStore = newStateStore
+	NewSpool      = newSpool
+	LintCrontab   = lintCrontab
 )
+
+func NewTestApp(opt *Option) *App {
+	return &App{
+		option: opt,
+		ctx:    context.Background(),
+		stats:  &Stats{},
+		paused: make(map[string]bool),
+	}
+}
+
+func (app *App) Load() error {
+	return app.load()
+}
+
+func (app *App) CheckCrontab() error {
+	return app.checkCrontab()
+}
+
+func (app *App) Entries() []cron.Entry {
+	return app.cron.Entries()
+}
+
+func (app *App) Envs() Environments {
+	return app.envs
+}
+
+func (app *App) SetSQSEndpoint(endpoint string) {
+	app.sess = session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("ap-northeast-1"),
+		Endpoint:    aws.String(endpoint),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	app.sender = newSQSSender(app.sess)
+}
+
+func (app *App) SetDestination(dest string) error {
+	app.option.Destination = dest
+	s, err := newSender(app.option, app.sess, app.stats)
+	if err != nil {
+		return err
+	}
+	app.sender = s
+	return nil
+}
+
+func (app *App) Send(msg *Message) error {
+	return app.send(msg)
+}
+
+func (app *App) SetSpool(dir string) error {
+	s, err := newSpool(dir, time.Hour, app.stats)
+	if err != nil {
+		return err
+	}
+	app.spool = s
+	return nil
+}
+
+func (app *App) RetrySpool(now time.Time) {
+	app.spool.Retry(now, app.resend)
+}
+
+func (app *App) CloseSender() {
+	app.closeSender()
+}
+
+func (app *App) Stats() *Stats {
+	return app.stats
+}
+
+func (app *App) WriteMetrics(w io.Writer) error {
+	return app.writeMetrics(w)
+}
+
+func (app *App) EntryViews() map[string]*EntryView {
+	return app.entryViews()
+}
+
+func (app *App) NewMessage(j *Job, t time.Time) (*Message, error) {
+	return app.newMessage(j, t)
+}
+
+func (app *App) ServeAdmin(w http.ResponseWriter, r *http.Request) {
+	app.serveAdmin(w, r)
+}
+
+func (app *App) Next(from, to time.Time, n int) ([]*Preview, error) {
+	return app.next(from, to, n)
+}
+
+func (app *App) Simulate(from, to time.Time, w io.Writer) error {
+	return app.simulate(from, to, w)
+}
+
+func (app *App) Option() *Option {
+	return app.option
+}
+
+func (s *Stats) EntryStats(key string) *EntryStats {
+	return s.entry(key)
+}
+
+func (app *App) SetDedupURL(s string) error {
+	d, err := newDeduplicator(s, app.option.DedupTTL, app.sess)
+	if err != nil {
+		return err
+	}
+	app.dedup = d
+	return nil
+}
+
+func (app *App) SetLease(s string) error {
+	l, err := newLease(s, app.sess)
+	if err != nil {
+		return err
+	}
+	app.lease = l
+	app.stats.Leader = &LeaderStats{holder: newHolderID()}
+	return nil
+}
+
+func (app *App) SetLeaseBackend(l lease, d time.Duration) {
+	app.lease = l
+	app.option.LeaseDuration = d
+	app.stats.Leader = &LeaderStats{holder: newHolderID()}
+}
+
+func (app *App) Elect() {
+	app.elect(time.Now())
+}
+
+func (app *App) Resign() {
+	app.resign()
+}
+
+func (s *LeaderStats) IsLeader() bool {
+	return s.isLeader()
+}
+
+func (app *App) SetStateURL(u string) {
+	app.state = newStateStore(u, app.sess)
+}
+
+func (app *App) State() *stateStore {
+	return app.state
+}
+
+func (app *App) SyncState() {
+	app.syncState()
+}
+
+func (app *App) CatchUp(now time.Time) {
+	app.catchUp(now)
+	app.wg.Wait()
+}
+
+func (j *Job) Invoke(t time.Time) {
+	j.invoke(t)
+}
+
+func (app *App) SetContext(ctx context.Context) {
+	app.ctx = ctx
+}
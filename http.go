@@ -0,0 +1,123 @@
+package sqsjfr
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers of HTTP requests sent by sqsjfr.
+const (
+	HeaderDeduplicationID = "X-Sqsjfr-Deduplication-Id"
+	HeaderMessageGroupID  = "X-Sqsjfr-Message-Group-Id"
+	HeaderIdempotencyKey  = "Idempotency-Key"
+	HeaderTimestamp       = "X-Sqsjfr-Timestamp"
+	HeaderSignature       = "X-Sqsjfr-Signature"
+)
+
+// DefaultHTTPTimeout defines a default timeout of HTTP requests to send messages.
+const DefaultHTTPTimeout = 10 * time.Second
+
+// httpError represents an unexpected response status of an HTTP destination.
+type httpError struct {
+	url        string
+	statusCode int
+	status     string
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected response from %s: %s", e.url, e.status)
+}
+
+// retryable reports whether the request may succeed by retrying.
+// Client errors never succeed, except for request timeout and too many requests.
+func (e *httpError) retryable() bool {
+	switch e.statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.statusCode < 400 || e.statusCode >= 500
+}
+
+// httpSender posts messages to an HTTP endpoint.
+type httpSender struct {
+	url     string
+	client  *http.Client
+	headers http.Header
+	secret  []byte
+}
+
+func newHTTPSender(u string, opt *Option) (*httpSender, error) {
+	headers, err := parseHTTPHeaders(opt.HTTPHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &httpSender{
+		url:     u,
+		client:  &http.Client{}, // timeout by the context of sendMessage
+		headers: headers,
+		secret:  []byte(opt.HTTPSecret),
+	}, nil
+}
+
+func (s *httpSender) Send(ctx context.Context, msg *Message) error {
+	body := msg.String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range s.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeduplicationID, msg.DeduplicationID())
+	req.Header.Set(HeaderIdempotencyKey, msg.DeduplicationID())
+	req.Header.Set(HeaderMessageGroupID, msg.MessageGroupID)
+	if len(s.secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, ts)
+		req.Header.Set(HeaderSignature, "sha256="+signHTTPBody(s.secret, ts, body))
+	}
+	log.Printf("[debug] posting message to %s", s.url)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return &httpError{url: s.url, statusCode: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// signHTTPBody returns a hex encoded HMAC-SHA256 of "{timestamp}.{body}" by the secret.
+func signHTTPBody(secret []byte, timestamp, body string) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp + "." + body))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// parseHTTPHeaders parses headers in "Name: value" format.
+func parseHTTPHeaders(ss []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, s := range ss {
+		p := strings.SplitN(s, ":", 2)
+		name := strings.TrimSpace(p[0])
+		if len(p) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, errors.Errorf("invalid HTTP header %s", s)
+		}
+		headers.Add(name, strings.TrimSpace(p[1]))
+	}
+	return headers, nil
+}
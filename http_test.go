@@ -0,0 +1,121 @@
+package sqsjfr_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestSendHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var headers []http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(b))
+		headers = append(headers, r.Header)
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	app := newRetryTestApp("")
+	if err := app.SetDestination(ts.URL + "/jobs"); err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestMessage(t, "")
+	if err := app.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("unexpected requests %d", len(bodies))
+	}
+	if bodies[1] != msg.String() {
+		t.Errorf("unexpected body %s", bodies[1])
+	}
+	if h := headers[1].Get(sqsjfr.HeaderDeduplicationID); h != msg.DeduplicationID() {
+		t.Errorf("unexpected deduplication ID %s", h)
+	}
+	if h := headers[1].Get(sqsjfr.HeaderIdempotencyKey); h != msg.DeduplicationID() {
+		t.Errorf("unexpected idempotency key %s", h)
+	}
+	if h := headers[1].Get("Content-Type"); h != "application/json" {
+		t.Errorf("unexpected content type %s", h)
+	}
+	if h := headers[1].Get(sqsjfr.HeaderSignature); h != "" {
+		t.Errorf("unexpected signature %s", h)
+	}
+}
+
+func TestSendHTTPSigned(t *testing.T) {
+	secret := "s3cr3t"
+	var received *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	app := newRetryTestApp("")
+	opt := app.Option()
+	opt.HTTPSecret = secret
+	opt.HTTPHeaders = []string{"Authorization: Bearer token", "X-Custom: a:b"}
+	if err := app.SetDestination(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestMessage(t, "")
+	if err := app.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if h := received.Header.Get("Authorization"); h != "Bearer token" {
+		t.Errorf("unexpected Authorization %s", h)
+	}
+	if h := received.Header.Get("X-Custom"); h != "a:b" {
+		t.Errorf("unexpected X-Custom %s", h)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(received.Header.Get(sqsjfr.HeaderTimestamp) + "." + string(body)))
+	if h := received.Header.Get(sqsjfr.HeaderSignature); h != fmt.Sprintf("sha256=%x", mac.Sum(nil)) {
+		t.Errorf("unexpected signature %s", h)
+	}
+}
+
+func TestSendHTTPClientError(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	app := newRetryTestApp("")
+	if err := app.SetDestination(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Send(newTestMessage(t, "")); err == nil {
+		t.Error("must be failed")
+	}
+	if requests != 1 {
+		t.Errorf("client error must not be retried: %d requests", requests)
+	}
+}
+
+func TestValidateHTTPHeaders(t *testing.T) {
+	for _, h := range []string{"NoColon", ": value", "Bad Name: value"} {
+		opt := &sqsjfr.Option{Destination: "https://example.com/", HTTPHeaders: []string{h}}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("header %s must be invalid", h)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package sqsjfr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/pkg/errors"
+)
+
+// KinesisBatchWindow defines a duration to wait for records scheduled in the same minute to put them at once.
+var KinesisBatchWindow = time.Second
+
+// Limits of a PutRecords request. The size of a record is the data and the partition key.
+const (
+	kinesisMaxRecords      = 500
+	kinesisMaxRecordBytes  = 1024 * 1024
+	kinesisMaxRequestBytes = 5 * 1024 * 1024
+)
+
+// kinesisRecord represents a record waiting to be put.
+type kinesisRecord struct {
+	ctx  context.Context
+	msg  *Message
+	data []byte
+	done chan error
+}
+
+func (r *kinesisRecord) size() int {
+	return len(r.data) + len(r.msg.PartitionKey)
+}
+
+// kinesisBatch represents records scheduled in the same minute.
+type kinesisBatch struct {
+	records []*kinesisRecord
+	bytes   int
+}
+
+// kinesisSender puts messages as records to a Kinesis data stream.
+// Records scheduled in the same minute are batched into PutRecords requests.
+type kinesisSender struct {
+	svc        *kinesis.Kinesis
+	streamName string
+	stats      *Stats
+
+	mu      sync.Mutex
+	batches map[int64]*kinesisBatch // by the invoked minute
+}
+
+func newKinesisSender(sess *session.Session, streamARN string, stats *Stats) (*kinesisSender, error) {
+	a, err := parseStreamARN(streamARN)
+	if err != nil {
+		return nil, err
+	}
+	return &kinesisSender{
+		svc:        kinesis.New(sess, aws.NewConfig().WithRegion(a.Region).WithMaxRetries(0)), // retried by sendWithRetry
+		streamName: strings.TrimPrefix(a.Resource, "stream/"),
+		stats:      stats,
+		batches:    make(map[int64]*kinesisBatch),
+	}, nil
+}
+
+// Send waits for the result of the batch even if the context is done, because the request may succeed after that.
+// The request of the batch is canceled by the earliest deadline of the records.
+func (s *kinesisSender) Send(ctx context.Context, msg *Message) error {
+	r := &kinesisRecord{ctx: ctx, msg: msg, data: []byte(msg.String()), done: make(chan error, 1)}
+	if n := r.size(); n > kinesisMaxRecordBytes {
+		s.countFailedRecord(r)
+		return awserr.New(kinesis.ErrCodeInvalidArgumentException, fmt.Sprintf("record size %d exceeds %d bytes", n, kinesisMaxRecordBytes), nil)
+	}
+	s.add(r)
+	return <-r.done
+}
+
+// add adds the record to the batch of the minute. The batch is flushed when it is full or after KinesisBatchWindow.
+func (s *kinesisSender) add(r *kinesisRecord) {
+	key := r.msg.InvokedAt / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[key]
+	if ok && b.bytes+r.size() > kinesisMaxRequestBytes {
+		// puts the current batch not to exceed the request size limit
+		delete(s.batches, key)
+		go s.put(b.records)
+		ok = false
+	}
+	if !ok {
+		b = &kinesisBatch{}
+		s.batches[key] = b
+		time.AfterFunc(KinesisBatchWindow, func() { s.flush(key, b) })
+	}
+	b.records = append(b.records, r)
+	b.bytes += r.size()
+	if len(b.records) >= kinesisMaxRecords {
+		delete(s.batches, key)
+		go s.put(b.records)
+	}
+}
+
+func (s *kinesisSender) flush(key int64, b *kinesisBatch) {
+	s.mu.Lock()
+	if s.batches[key] != b {
+		s.mu.Unlock()
+		return // already flushed
+	}
+	delete(s.batches, key)
+	s.mu.Unlock()
+	s.put(b.records)
+}
+
+// put puts the records by a PutRecords request, and notifies the result for each record.
+func (s *kinesisSender) put(records []*kinesisRecord) {
+	in := &kinesis.PutRecordsInput{
+		StreamName: aws.String(s.streamName),
+	}
+	deadline := time.Now().Add(SQSTimeout)
+	for _, r := range records {
+		in.Records = append(in.Records, &kinesis.PutRecordsRequestEntry{
+			Data:         r.data,
+			PartitionKey: aws.String(r.msg.PartitionKey),
+		})
+		if d, ok := r.ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	log.Printf("[debug] putting %d records to %s", len(records), s.streamName)
+	out, err := s.svc.PutRecordsWithContext(ctx, in)
+	if err != nil {
+		for _, r := range records {
+			s.countFailedRecord(r)
+			r.done <- err
+		}
+		return
+	}
+	if n := aws.Int64Value(out.FailedRecordCount); n > 0 {
+		log.Printf("[warn] %d of %d records failed to put to %s", n, len(records), s.streamName)
+	}
+	for i, r := range records {
+		if i >= len(out.Records) {
+			r.done <- errors.New("result of the record is not found")
+			continue
+		}
+		if e := out.Records[i]; e.ErrorCode != nil {
+			s.countFailedRecord(r)
+			r.done <- awserr.New(aws.StringValue(e.ErrorCode), aws.StringValue(e.ErrorMessage), nil)
+			continue
+		}
+		log.Printf("[debug] [entry:%s] put record %s", r.msg.entry(), aws.StringValue(out.Records[i].SequenceNumber))
+		r.done <- nil
+	}
+}
+
+// countFailedRecord counts a record which failed to put in the stats of the entry.
+func (s *kinesisSender) countFailedRecord(r *kinesisRecord) {
+	if s.stats == nil {
+		return
+	}
+	atomic.AddInt64(&s.stats.entry(r.msg.entry()).FailedRecords, 1)
+}
+
+// arn:aws:kinesis:ap-northeast-1:123456789012:stream/stream_name
+func parseStreamARN(s string) (arn.ARN, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return a, errors.Wrapf(err, "invalid stream ARN:%s", s)
+	}
+	if a.Service != "kinesis" || a.Region == "" || !strings.HasPrefix(a.Resource, "stream/") || a.Resource == "stream/" {
+		return a, errors.Errorf("invalid stream ARN:%s", s)
+	}
+	return a, nil
+}
+
+// setPartitionKey sets a partition key rendered by the template of the entry or the option.
+// The default is the name (or the stable ID) of the entry.
+func setPartitionKey(opt *Option, j *Job, msg *Message) error {
+	tmpl := firstNonEmpty(j.PartitionKey, opt.PartitionKey)
+	if tmpl == "" {
+		msg.PartitionKey = msg.entry()
+		return nil
+	}
+	key, err := renderTemplateField("partition-key", tmpl, msg)
+	if err != nil {
+		return err
+	}
+	msg.PartitionKey = key
+	return nil
+}
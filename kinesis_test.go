@@ -0,0 +1,205 @@
+package sqsjfr_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+const testStreamARN = "arn:aws:kinesis:ap-northeast-1:123456789012:stream/test"
+
+type putRecordsEntry struct {
+	Data         []byte
+	PartitionKey string
+}
+
+func TestSendKinesis(t *testing.T) {
+	defer func(d time.Duration) { sqsjfr.KinesisBatchWindow = d }(sqsjfr.KinesisBatchWindow)
+	sqsjfr.KinesisBatchWindow = 100 * time.Millisecond
+	var mu sync.Mutex
+	var requests [][]putRecordsEntry
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "Kinesis_20131202.PutRecords" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var in struct {
+			StreamName string
+			Records    []putRecordsEntry
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.StreamName != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, in.Records)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if len(requests) == 1 {
+			// the second record fails at first
+			w.Write([]byte(`{"FailedRecordCount":1,"Records":[{"SequenceNumber":"1","ShardId":"shardId-0"},{"ErrorCode":"ProvisionedThroughputExceededException","ErrorMessage":"slow down"}]}`))
+			return
+		}
+		w.Write([]byte(`{"FailedRecordCount":0,"Records":[{"SequenceNumber":"2","ShardId":"shardId-0"}]}`))
+	}))
+	defer ts.Close()
+
+	app := newRetryTestApp(ts.URL)
+	app.Option().PartitionKey = "{{.Command}}"
+	if err := app.SetDestination("kinesis://" + testStreamARN); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range []string{"first", "second"} {
+		j := &sqsjfr.Job{Name: name, Command: "echo " + name, Location: time.UTC}
+		msg, err := app.NewMessage(j, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.Send(msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(requests) != 2 {
+		t.Fatalf("unexpected requests %d", len(requests))
+	}
+	if len(requests[0]) != 2 || len(requests[1]) != 1 {
+		t.Fatalf("unexpected records %d %d", len(requests[0]), len(requests[1]))
+	}
+	failed := requests[0][1].PartitionKey
+	if requests[1][0].PartitionKey != failed {
+		t.Errorf("unexpected retried record %s", requests[1][0].PartitionKey)
+	}
+	var msg sqsjfr.Message
+	if err := json.Unmarshal(requests[1][0].Data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Command != failed {
+		t.Errorf("unexpected partition key %s of %s", failed, msg.Command)
+	}
+	stats := app.Stats()
+	for _, name := range []string{"first", "second"} {
+		var retried int64
+		if "echo "+name == failed {
+			retried = 1
+		}
+		e := stats.EntryStats(name)
+		if e.Succeeded != 1 || e.Retried != retried || e.FailedRecords != retried {
+			t.Errorf("unexpected stats of %s %#v", name, e)
+		}
+	}
+}
+
+func TestSendKinesisLargeRecords(t *testing.T) {
+	defer func(d time.Duration) { sqsjfr.KinesisBatchWindow = d }(sqsjfr.KinesisBatchWindow)
+	sqsjfr.KinesisBatchWindow = 100 * time.Millisecond
+	var mu sync.Mutex
+	var requests []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Records []putRecordsEntry
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, len(in.Records))
+		mu.Unlock()
+		results := make([]string, len(in.Records))
+		for i := range results {
+			results[i] = `{"SequenceNumber":"1","ShardId":"shardId-0"}`
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"FailedRecordCount":0,"Records":[` + strings.Join(results, ",") + `]}`))
+	}))
+	defer ts.Close()
+
+	app := newRetryTestApp(ts.URL)
+	if err := app.SetDestination("kinesis://" + testStreamARN); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	send := func(name string, size int) error {
+		j := &sqsjfr.Job{Name: name, Command: strings.Repeat("x", size), Location: time.UTC}
+		msg, err := app.NewMessage(j, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return app.Send(msg)
+	}
+
+	// a record larger than 1 MiB is never put
+	if err := send("huge", 1024*1024); err == nil {
+		t.Error("too large record must be failed")
+	}
+	if e := app.Stats().EntryStats("huge"); e.Retried != 0 || e.FailedRecords != 1 {
+		t.Errorf("unexpected stats of huge %#v", e)
+	}
+	if len(requests) != 0 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	// 6 records of 900 KiB are split into requests up to 5 MiB
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := send(fmt.Sprintf("large%d", i), 900*1024); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(requests) != 2 || requests[0]+requests[1] != 6 {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
+
+func TestKinesisPartitionKey(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{Destination: "kinesis://" + testStreamARN})
+	for _, j := range []*sqsjfr.Job{
+		{Name: "named", Command: "date", Location: time.UTC},
+		{StableID: "0123456789abcdef", Command: "date", Location: time.UTC},
+		{Name: "templated", Command: "date", Location: time.UTC, PartitionKey: "{{.EntryName}}-{{.InvokedAt}}"},
+	} {
+		msg, err := app.NewMessage(j, time.Unix(60, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := j.String()
+		if j.PartitionKey != "" {
+			expected = j.Name + "-60"
+		}
+		if msg.PartitionKey != expected {
+			t.Errorf("unexpected partition key %s expected %s", msg.PartitionKey, expected)
+		}
+	}
+}
+
+func TestValidateKinesisPartitionKey(t *testing.T) {
+	opt := &sqsjfr.Option{Destination: "kinesis://" + testStreamARN}
+	_, _, _, err := sqsjfr.ReadCrontab(strings.NewReader("[partition-key={{.EntryName}}] * * * * * date"), opt, newJob)
+	if err != nil {
+		t.Error(err)
+	}
+	opt.PartitionKey = "{{.Env.TENANT}}"
+	if err := opt.Validate(); err != nil {
+		t.Error(err)
+	}
+}
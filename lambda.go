@@ -0,0 +1,105 @@
+package sqsjfr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/pkg/errors"
+)
+
+// lambdaSender invokes Lambda functions asynchronously with messages.
+type lambdaSender struct {
+	svc         *lambda.Lambda
+	functionARN string
+}
+
+func newLambdaSender(sess *session.Session, functionARN string) (*lambdaSender, error) {
+	a, err := parseFunctionARN(functionARN)
+	if err != nil {
+		return nil, err
+	}
+	return &lambdaSender{
+		svc:         lambda.New(sess, aws.NewConfig().WithRegion(a.Region).WithMaxRetries(0)), // retried by sendWithRetry
+		functionARN: functionARN,
+	}, nil
+}
+
+func (s *lambdaSender) Send(ctx context.Context, msg *Message) error {
+	payload, err := lambdaPayload(msg)
+	if err != nil {
+		return err
+	}
+	functionARN := s.functionARN
+	if msg.FunctionARN != "" {
+		functionARN = msg.FunctionARN
+	}
+	in := &lambda.InvokeInput{
+		FunctionName:   aws.String(functionARN),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        payload,
+	}
+	log.Printf("[debug] invoking function %s: %s", functionARN, payload)
+	out, err := s.svc.InvokeWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+	log.Printf("[debug] invoked function %s status %d", functionARN, aws.Int64Value(out.StatusCode))
+	return nil
+}
+
+// lambdaPayload returns a payload of the message with the deduplication ID, so functions can deduplicate invocations.
+func lambdaPayload(msg *Message) ([]byte, error) {
+	dec := json.NewDecoder(strings.NewReader(msg.String()))
+	dec.UseNumber()
+	var payload map[string]interface{}
+	if err := dec.Decode(&payload); err != nil {
+		return nil, errors.Wrap(err, "a message for Lambda must be a JSON object")
+	}
+	payload["deduplication_id"] = msg.DeduplicationID()
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(payload); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// arn:aws:lambda:ap-northeast-1:123456789012:function:function_name[:alias]
+func parseFunctionARN(s string) (arn.ARN, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return a, errors.Wrapf(err, "invalid function ARN:%s", s)
+	}
+	if a.Service != "lambda" || a.Region == "" || !strings.HasPrefix(a.Resource, "function:") || a.Resource == "function:" {
+		return a, errors.Errorf("invalid function ARN:%s", s)
+	}
+	return a, nil
+}
+
+// resolveFunctionARN resolves a function name (with an alias) or a function ARN based on the default function ARN.
+func resolveFunctionARN(base, s string) (string, error) {
+	b, err := parseFunctionARN(base)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(s, "arn:") {
+		b.Resource = "function:" + s
+		s = b.String()
+	}
+	a, err := parseFunctionARN(s)
+	if err != nil {
+		return "", err
+	}
+	if a.Region != b.Region {
+		return "", errors.Errorf("function %s must be in region %s", s, b.Region)
+	}
+	return s, nil
+}
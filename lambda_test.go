@@ -0,0 +1,95 @@
+package sqsjfr_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+const testFunctionARN = "arn:aws:lambda:ap-northeast-1:123456789012:function:test"
+
+func TestSendLambda(t *testing.T) {
+	var functions []string
+	var payloads []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Invocation-Type") != "Event" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p := strings.TrimSuffix(strings.TrimPrefix(r.URL.EscapedPath(), "/2015-03-31/functions/"), "/invocations")
+		name, _ := url.PathUnescape(p)
+		functions = append(functions, name)
+		b, _ := ioutil.ReadAll(r.Body)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(b, &payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL:  "tests/crontab.lambda",
+		Destination: "lambda://" + testFunctionARN,
+		Timezone:    "UTC",
+	})
+	app.SetSQSEndpoint(ts.URL)
+	if err := app.SetDestination("lambda://" + testFunctionARN); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, entry := range app.Entries() {
+		msg, err := app.NewMessage(entry.Job.(*sqsjfr.Job), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, msg.DeduplicationID())
+	}
+	expected := [][]string{
+		{"arn:aws:lambda:ap-northeast-1:123456789012:function:heavy:live", "echo heavy"},
+		{testFunctionARN, "echo default"},
+	}
+	if len(functions) != len(expected) {
+		t.Fatalf("unexpected invocations %d", len(functions))
+	}
+	for i, e := range expected {
+		if functions[i] != e[0] {
+			t.Errorf("unexpected function %s expected %s", functions[i], e[0])
+		}
+		if payloads[i]["command"] != e[1] || payloads[i]["deduplication_id"] != ids[i] {
+			t.Errorf("unexpected payload %#v", payloads[i])
+		}
+	}
+}
+
+func TestValidateLambda(t *testing.T) {
+	for _, dest := range []string{
+		"lambda://arn:aws:lambda:ap-northeast-1:123456789012:layer:test",
+		"lambda://test",
+	} {
+		opt := &sqsjfr.Option{Destination: dest}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("destination %s must be invalid", dest)
+		}
+	}
+	opt := &sqsjfr.Option{Destination: "lambda://" + testFunctionARN}
+	_, _, _, err := sqsjfr.ReadCrontab(strings.NewReader("[function=arn:aws:lambda:us-east-1:123456789012:function:other] * * * * * date\n"), opt, newJob)
+	if err == nil {
+		t.Error("function in another region must be invalid")
+	}
+}
@@ -0,0 +1,299 @@
+package sqsjfr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+)
+
+// DefaultLeaseDuration defines the default duration of the leader lease.
+const DefaultLeaseDuration = 15 * time.Second
+
+// MinLeaseDuration defines the minimum duration of the leader lease.
+const MinLeaseDuration = 3 * time.Second
+
+// DefaultLeaseName defines the default name of the lease in a DynamoDB table.
+const DefaultLeaseName = "sqsjfr"
+
+// lease is a backend of leader election.
+type lease interface {
+	// Acquire acquires or renews the lease for the holder, and reports whether the holder is the leader.
+	Acquire(ctx context.Context, holder string, d time.Duration) (bool, error)
+	// Release releases the lease held by the holder.
+	Release(ctx context.Context, holder string) error
+}
+
+// parseLeaseURL parses dynamodb://{table}[/{name}] or a local file path.
+func parseLeaseURL(s string) (scheme, target, name string, err error) {
+	if rest := strings.TrimPrefix(s, "dynamodb://"); rest != s {
+		p := strings.SplitN(rest, "/", 2)
+		table, name := p[0], DefaultLeaseName
+		if len(p) == 2 {
+			name = p[1]
+		}
+		if table == "" || name == "" {
+			return "", "", "", errors.Errorf("invalid lease URL %s: table name is required", s)
+		}
+		return "dynamodb", table, name, nil
+	}
+	if i := strings.Index(s, "://"); i > 0 {
+		return "", "", "", errors.Errorf("lease URL scheme %s is not supported", s[:i])
+	}
+	return "file", s, "", nil
+}
+
+func newLease(s string, sess *session.Session) (lease, error) {
+	scheme, target, name, err := parseLeaseURL(s)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == "dynamodb" {
+		return &dynamoDBLease{
+			svc:   dynamodb.New(sess, aws.NewConfig().WithMaxRetries(0)), // retried by the next renewal
+			table: target,
+			name:  name,
+		}, nil
+	}
+	return newFlockLease(target)
+}
+
+// dynamoDBLease is a lease stored in a DynamoDB table which has a partition key "id" (string).
+type dynamoDBLease struct {
+	svc   *dynamodb.DynamoDB
+	table string
+	name  string
+}
+
+func (l *dynamoDBLease) Acquire(ctx context.Context, holder string, d time.Duration) (bool, error) {
+	now := time.Now()
+	millis := func(t time.Time) *string {
+		return aws.String(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+	}
+	_, err := l.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":            {S: aws.String(l.name)},
+			"holder":        {S: aws.String(holder)},
+			"expires_at_ms": {N: millis(now.Add(d))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(id) OR holder = :holder OR expires_at_ms < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":holder": {S: aws.String(holder)},
+			":now":    {N: millis(now)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *dynamoDBLease) Release(ctx context.Context, holder string) error {
+	_, err := l.svc.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(l.name)},
+		},
+		ConditionExpression: aws.String("holder = :holder"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":holder": {S: aws.String(holder)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil // held by another process already
+	}
+	return err
+}
+
+// newHolderID returns an ID which identifies this process among candidates.
+func newHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// LeaderStats represents the status of leader election.
+type LeaderStats struct {
+	mu          sync.Mutex
+	holder      string
+	leader      bool
+	since       time.Time
+	renewedAt   time.Time
+	transitions int64
+	lastError   string
+}
+
+func (s *LeaderStats) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(struct {
+		Holder      string     `json:"holder"`
+		IsLeader    bool       `json:"is_leader"`
+		Since       *time.Time `json:"since,omitempty"`
+		RenewedAt   *time.Time `json:"renewed_at,omitempty"`
+		Transitions int64      `json:"transitions"`
+		LastError   string     `json:"last_error,omitempty"`
+	}{
+		Holder:      s.holder,
+		IsLeader:    s.leader,
+		Since:       timePtr(s.since),
+		RenewedAt:   timePtr(s.renewedAt),
+		Transitions: s.transitions,
+		LastError:   s.lastError,
+	})
+}
+
+func (s *LeaderStats) isLeader() bool {
+	leader, _ := s.snapshot()
+	return leader
+}
+
+func (s *LeaderStats) snapshot() (leader bool, transitions int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader, s.transitions
+}
+
+// runLeaderElection runs the scheduler only while this process holds the lease, until the context is canceled.
+func (app *App) runLeaderElection() {
+	interval := app.option.leaseDuration() / 3
+	log.Printf("[info] starting leader election as %s (lease %s)", app.stats.Leader.holder, app.option.leaseDuration())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		app.elect(time.Now())
+		select {
+		case <-app.ctx.Done():
+			app.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elect acquires or renews the lease, and starts or stops the scheduler by the result.
+// The leader steps down at 2/3 of the lease duration since the last renewal, so the rest (1/3) of the duration is a margin for clock skew among processes.
+func (app *App) elect(now time.Time) {
+	d := app.option.leaseDuration()
+	s := app.stats.Leader
+	s.mu.Lock()
+	leader, renewedAt := s.leader, s.renewedAt
+	s.mu.Unlock()
+	deadline := now.Add(d / 3)
+	if leader {
+		deadline = renewedAt.Add(d - d/3)
+	}
+	ctx, cancel := context.WithDeadline(app.ctx, deadline)
+	defer cancel()
+	ok, err := app.lease.Acquire(ctx, s.holder, d)
+	// a renewal after the deadline may be too late, whatever the result is
+	late := leader && !time.Now().Before(deadline)
+
+	var stopped context.Context
+	s.mu.Lock()
+	switch {
+	case err != nil:
+		log.Printf("[warn] failed to acquire the lease: %s", err)
+		s.lastError = err.Error()
+		if late && s.leader {
+			stopped = app.stepDown(s, now, "the lease could not be renewed")
+		}
+	case late:
+		s.lastError = ""
+		if s.leader {
+			stopped = app.stepDown(s, now, "the lease was not renewed in time")
+		}
+	case ok:
+		s.lastError = ""
+		s.renewedAt = now
+		if !s.leader {
+			app.becomeLeader(s, now)
+		}
+	default:
+		s.lastError = ""
+		if s.leader {
+			stopped = app.stepDown(s, now, "the lease is held by another process")
+		}
+	}
+	s.mu.Unlock()
+	if stopped != nil {
+		// waits for running jobs not to send messages with a new leader
+		<-stopped.Done()
+	}
+}
+
+func (app *App) becomeLeader(s *LeaderStats, now time.Time) {
+	log.Printf("[info] became the leader as %s", s.holder)
+	s.leader = true
+	s.since = now
+	s.transitions++
+	if app.state != nil {
+		// continues from the state saved by the previous leader
+		if err := app.state.Load(); err != nil {
+			log.Println("[warn]", err)
+		}
+		app.catchUp(now)
+	}
+	app.cron.Start()
+}
+
+// stepDown stops the scheduler, and returns a context which is done when running jobs are completed.
+func (app *App) stepDown(s *LeaderStats, now time.Time, reason string) context.Context {
+	log.Printf("[warn] stepped down from the leader: %s", reason)
+	stopped := app.cron.Stop()
+	s.leader = false
+	s.since = now
+	s.transitions++
+	return stopped
+}
+
+// resign stops the scheduler and releases the lease, so that another process can be the leader immediately.
+func (app *App) resign() {
+	s := app.stats.Leader
+	s.mu.Lock()
+	if !s.leader {
+		s.mu.Unlock()
+		return
+	}
+	stopped := app.cron.Stop()
+	s.leader = false
+	s.since = time.Now()
+	s.transitions++
+	s.mu.Unlock()
+
+	if app.state != nil {
+		// saves the last fired times before another process becomes the leader
+		<-stopped.Done()
+		if err := app.state.Save(); err != nil {
+			log.Println("[warn]", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), app.option.leaseDuration()/3)
+	defer cancel()
+	if err := app.lease.Release(ctx, s.holder); err != nil {
+		log.Printf("[warn] failed to release the lease: %s", err)
+		return
+	}
+	log.Printf("[info] released the lease")
+}
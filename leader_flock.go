@@ -0,0 +1,67 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sqsjfr
+
+import (
+	"context"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// flockLease is a lease by flock(2) on a local file, for multiple processes on a host.
+// The lock is released by the OS when the holder process exits.
+type flockLease struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFlockLease(path string) (lease, error) {
+	if path == "" {
+		return nil, errors.New("lease file path is required")
+	}
+	return &flockLease{path: path}, nil
+}
+
+func (l *flockLease) Acquire(ctx context.Context, holder string, d time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		return true, nil // the lock is held until released
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "failed to lock %s", l.path)
+	}
+	// records the holder for operators
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(holder+"\n"), 0)
+	}
+	l.f = f
+	return true, nil
+}
+
+func (l *flockLease) Release(ctx context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package sqsjfr
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func newFlockLease(path string) (lease, error) {
+	return nil, errors.Errorf("lease by a local file is not supported on %s", runtime.GOOS)
+}
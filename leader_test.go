@@ -0,0 +1,153 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sqsjfr_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func newLeaderTestApp(t *testing.T, leaseURL string) *sqsjfr.App {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		Timezone:   "UTC",
+		LeaseURL:   leaseURL,
+	})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SetLease(leaseURL); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestLeaderElectionFlock(t *testing.T) {
+	leaseURL := filepath.Join(t.TempDir(), "sqsjfr.lock")
+	app1 := newLeaderTestApp(t, leaseURL)
+	app2 := newLeaderTestApp(t, leaseURL)
+
+	app1.Elect()
+	app2.Elect()
+	if !app1.Stats().Leader.IsLeader() || app2.Stats().Leader.IsLeader() {
+		t.Fatal("app1 must be the only leader")
+	}
+	// renews the lease
+	app1.Elect()
+	if !app1.Stats().Leader.IsLeader() {
+		t.Error("app1 must be the leader after renewal")
+	}
+
+	// fails over after the leader resigned
+	app1.Resign()
+	app2.Elect()
+	app1.Elect()
+	if app1.Stats().Leader.IsLeader() || !app2.Stats().Leader.IsLeader() {
+		t.Error("app2 must be the only leader")
+	}
+	app2.Resign()
+
+	b, err := json.Marshal(app1.Stats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats struct {
+		Leader struct {
+			Holder      string `json:"holder"`
+			IsLeader    bool   `json:"is_leader"`
+			Transitions int64  `json:"transitions"`
+		} `json:"leader"`
+	}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Leader.Holder == "" || stats.Leader.IsLeader || stats.Leader.Transitions != 2 {
+		t.Errorf("unexpected leader stats %s", b)
+	}
+
+	var buf bytes.Buffer
+	if err := app2.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "sqsjfr_leader_transitions_total 2\n") {
+		t.Errorf("leader metrics not found in %s", buf.String())
+	}
+}
+
+// slowLease is a lease which is acquired immediately, or blocks until the context is done when slow.
+type slowLease struct {
+	slow bool
+}
+
+func (l *slowLease) Acquire(ctx context.Context, holder string, d time.Duration) (bool, error) {
+	if l.slow {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return true, nil
+}
+
+func (l *slowLease) Release(ctx context.Context, holder string) error {
+	return nil
+}
+
+func TestLeaderStepsDownBeforeLeaseExpires(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		Timezone:   "UTC",
+	})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	d := 300 * time.Millisecond
+	l := &slowLease{}
+	app.SetLeaseBackend(l, d)
+	renewed := time.Now()
+	app.Elect()
+	if !app.Stats().Leader.IsLeader() {
+		t.Fatal("app must be the leader")
+	}
+	// the renewal does not respond until the lease expires
+	l.slow = true
+	app.Elect()
+	if app.Stats().Leader.IsLeader() {
+		t.Error("app must step down when the lease could not be renewed")
+	}
+	if e := time.Since(renewed); e >= d {
+		t.Errorf("app must step down before the lease expires, but %s elapsed", e)
+	}
+}
+
+func TestValidateLeaseURL(t *testing.T) {
+	for _, u := range []string{"dynamodb://", "dynamodb://table/", "etcd://localhost:2379"} {
+		opt := &sqsjfr.Option{
+			QueueURL: "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+			LeaseURL: u,
+		}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("lease URL %s must be invalid", u)
+		}
+	}
+	opt := &sqsjfr.Option{
+		QueueURL:      "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		LeaseURL:      "dynamodb://sqsjfr-lease/production",
+		LeaseDuration: sqsjfr.DefaultLeaseDuration,
+	}
+	if err := opt.Validate(); err != nil {
+		t.Error(err)
+	}
+	opt.LeaseDuration = sqsjfr.MinLeaseDuration / 2
+	if err := opt.Validate(); err == nil {
+		t.Error("too short lease duration must be invalid")
+	}
+}
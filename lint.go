@@ -0,0 +1,170 @@
+package sqsjfr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/go-envparse"
+)
+
+// Severities of diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var (
+	reCommandEnvRef  = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+	reTemplateEnvRef = regexp.MustCompile(`\.Env\.([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// Diagnostic represents a problem found in crontab.
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Entry    string `json:"entry,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Lint reads the crontab of the option and reports all problems found.
+func Lint(opt *Option) ([]*Diagnostic, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	f, err := readCrontabFile(opt.CrontabURL, sess)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lintCrontab(f, opt)
+}
+
+// lintEntry represents an entry to be checked after reading whole crontab.
+type lintEntry struct {
+	line int
+	job  *Job
+}
+
+// lintCrontab reads crontab like readCrontab, but continues on errors to report all problems.
+func lintCrontab(r io.Reader, opt *Option) ([]*Diagnostic, error) {
+	loc, err := opt.location()
+	if err != nil {
+		return nil, err
+	}
+	var diags []*Diagnostic
+	report := func(line int, severity string, j *Job, format string, args ...interface{}) {
+		d := &Diagnostic{Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)}
+		if j != nil {
+			d.Entry = j.String()
+		}
+		diags = append(diags, d)
+	}
+
+	now := time.Now()
+	scanner := bufio.NewScanner(r)
+	lines := 0
+	envsBuf := bytes.NewBuffer([]byte{})
+	reg := newEntryRegistry()
+	var entries []lintEntry
+	for scanner.Scan() {
+		lines++
+		line := reTrimPrefix.ReplaceAllString(scanner.Text(), "")
+		reg.comment(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "CRON_TZ=") {
+			l, err := parseCronTZ(line)
+			if err != nil {
+				report(lines, SeverityError, nil, "invalid CRON_TZ: %s", err)
+				continue
+			}
+			loc = l
+			continue
+		}
+		if reLooksLikeEnv.MatchString(line) {
+			if _, err := envparse.Parse(strings.NewReader(line)); err != nil {
+				report(lines, SeverityError, nil, "invalid environment variable: %s", err)
+				continue
+			}
+			envsBuf.WriteString(line + "\n")
+			continue
+		}
+		e, err := parseEntry(line, loc, opt)
+		if err != nil {
+			report(lines, SeverityError, nil, "%s", err)
+			reg.takeName("") // the name comment is not for the next entry
+			continue
+		}
+		j := &Job{}
+		j.setEntry(e, reg.takeName(e.options.Name))
+		if j.Name != "" && !reEntryName.MatchString(j.Name) {
+			report(lines, SeverityError, nil, "invalid name %s", j.Name)
+			j.Name = ""
+		}
+		if err := reg.register(lines, j.Name, e); err != nil {
+			report(lines, SeverityError, j, "%s", err)
+		}
+		if e.schedule.Next(now).IsZero() {
+			report(lines, SeverityError, j, "schedule %s never fires", e.spec)
+		}
+		if opt.ScopedEnv {
+			envs, _ := envparse.Parse(bytes.NewReader(envsBuf.Bytes()))
+			j.Env = Environments(envs)
+		}
+		entries = append(entries, lintEntry{line: lines, job: j})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	global, _ := envparse.Parse(envsBuf)
+	for _, e := range entries {
+		j := e.job
+		envs := Environments(global)
+		if j.Env != nil {
+			envs = j.Env
+		}
+		for _, m := range reCommandEnvRef.FindAllStringSubmatch(j.Command, -1) {
+			ref := m[1] + m[2]
+			if _, ok := envs[ref]; !ok {
+				report(e.line, SeverityWarning, j, "environment variable %s in the command is not defined", ref)
+			}
+		}
+		tmpl := opt.MessageTemplate
+		if j.MessageTemplate != "" {
+			tmpl = j.MessageTemplate
+		}
+		if tmpl != "" {
+			if b, err := ioutil.ReadFile(tmpl); err == nil {
+				for _, m := range reTemplateEnvRef.FindAllStringSubmatch(string(b), -1) {
+					if _, ok := envs[m[1]]; !ok {
+						report(e.line, SeverityWarning, j, "environment variable %s in template %s is not defined", m[1], tmpl)
+					}
+				}
+			}
+		}
+		msg, err := newMessage(j, tmpl, now.In(j.Location), opt.precision(), envs)
+		if err != nil {
+			report(e.line, SeverityError, j, "%s", err)
+			continue
+		}
+		if err := setDestinationFields(opt, j, msg); err != nil {
+			report(e.line, SeverityError, j, "%s", err)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, k int) bool {
+		return diags[i].Line < diags[k].Line
+	})
+	return diags, nil
+}
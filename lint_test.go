@@ -0,0 +1,69 @@
+package sqsjfr_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestLintCrontab(t *testing.T) {
+	f, err := os.Open("tests/crontab.lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	diags, err := sqsjfr.LintCrontab(f, &sqsjfr.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"6 error report",
+		"7 error 00a7ce2034d58ebc",
+		"8 error ",
+		"9 error ",
+		"10 error 00a7ce2034d58ebc",
+		"10 error 00a7ce2034d58ebc",
+		"11 warning 758067318e69d06f",
+		"11 warning 758067318e69d06f",
+		"12 error ",
+	}
+	if len(diags) != len(expected) {
+		for _, d := range diags {
+			t.Logf("%#v", d)
+		}
+		t.Fatalf("unexpected diagnostics %d expected %d", len(diags), len(expected))
+	}
+	for i, d := range diags {
+		if s := fmt.Sprintf("%d %s %s", d.Line, d.Severity, d.Entry); s != expected[i] {
+			t.Errorf("unexpected diagnostic %s expected %s: %s", s, expected[i], d.Message)
+		}
+	}
+	if m := diags[4].Message; m != "the same entry as line 7 (name this entry)" {
+		t.Errorf("unexpected message %s", m)
+	}
+	if m := diags[6].Message; m != "environment variable UNDEFINED in the command is not defined" {
+		t.Errorf("unexpected message %s", m)
+	}
+	if m := diags[7].Message; m != "environment variable APP_ENV in template tests/message.template.lint is not defined" {
+		t.Errorf("unexpected message %s", m)
+	}
+}
+
+func TestLintCrontabValid(t *testing.T) {
+	for _, name := range []string{"tests/crontab", "tests/crontab.tz", "tests/crontab.names"} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diags, err := sqsjfr.LintCrontab(f, &sqsjfr.Option{MessageTemplate: "tests/message.template"})
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range diags {
+			t.Errorf("%s: unexpected diagnostic %#v", name, d)
+		}
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/kayac/go-config"
+	"github.com/pkg/errors"
 )
 
 // Environments represents environment variables map defined in crontab.
@@ -25,7 +29,22 @@ type Message struct {
 	Command   string                 `json:"command"`
 	InvokedAt int64                  `json:"invoked_at"`
 	EntryID   int                    `json:"entry_id"`
+	EntryKey  string                 `json:"entry_key"`
+	EntryName string                 `json:"entry_name,omitempty"`
 	Env       Environments           `json:"envs"`
+	Timezone  string                 `json:"timezone"`
+	Manual    bool                   `json:"manual,omitempty"`
+
+	QueueURL        string `json:"-"`
+	MessageGroupID  string `json:"-"`
+	EventSource     string `json:"-"`
+	EventDetailType string `json:"-"`
+	PartitionKey    string `json:"-"`
+	FunctionARN     string `json:"-"`
+	DelaySeconds    int64  `json:"-"`
+
+	deduplicationID string // preserved deduplication ID of a spooled message
+	nonce           int64  // makes a deduplication ID of a manual invocation unique
 }
 
 func (m Message) String() string {
@@ -39,18 +58,49 @@ func (m Message) String() string {
 }
 
 func (m Message) DeduplicationID() string {
+	if m.deduplicationID != "" {
+		return m.deduplicationID
+	}
 	h := sha256.New()
-	h.Write([]byte(m.String()))
+	h.Write([]byte(m.entry()))
+	h.Write([]byte(m.stableBody()))
 	h.Write([]byte(strconv.FormatInt(m.InvokedAt, 10)))
+	if m.Manual {
+		h.Write([]byte("manual:" + strconv.FormatInt(m.nonce, 10)))
+	}
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-func newMessage(command, messageTemplate string, now time.Time, envs Environments) (*Message, error) {
-	min := now.Truncate(time.Minute)
+// stableBody returns the body without the position of the entry, which is changed by editing other lines.
+// A deduplication ID must be the same among processes which may have loaded different revisions of crontab.
+func (m Message) stableBody() string {
+	if m.Body == nil {
+		m.EntryID = 0
+	}
+	return m.String()
+}
+
+// entry returns the name (or the stable ID) of the entry of the message.
+func (m Message) entry() string {
+	if m.EntryName != "" {
+		return m.EntryName
+	}
+	return m.EntryKey
+}
+
+func newMessage(j *Job, messageTemplate string, now time.Time, precision time.Duration, envs Environments) (*Message, error) {
 	msg := Message{
-		Command:   command,
-		InvokedAt: min.Unix(),
+		Command:   j.Command,
+		EntryID:   int(atomic.LoadInt64(&j.index)),
+		EntryKey:  j.StableID,
+		EntryName: j.Name,
+		InvokedAt: now.Truncate(precision).Unix(),
 		Env:       envs,
+		Timezone:  now.Location().String(),
+	}
+	if j.manual {
+		msg.Manual = true
+		msg.nonce = time.Now().UnixNano()
 	}
 	if messageTemplate == "" {
 		return &msg, nil
@@ -63,3 +113,42 @@ func newMessage(command, messageTemplate string, now time.Time, envs Environment
 	}
 	return &msg, nil
 }
+
+// checkTemplateField checks a template of a field of the destination on loading.
+// Environment variables and names of entries are not known yet, so the template is executed with an empty message
+// to find syntax errors and unknown fields only. Templates are rendered with messages on sending.
+func checkTemplateField(name, tmpl string) error {
+	t, err := template.New(name).Parse(tmpl)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s template %s", name, tmpl)
+	}
+	if err := t.Execute(ioutil.Discard, &Message{Env: Environments{}}); err != nil {
+		return errors.Wrapf(err, "invalid %s template %s", name, tmpl)
+	}
+	return nil
+}
+
+// renderTemplateField renders a template of a field of the destination (e.g. Source of events) with the message.
+func renderTemplateField(name, tmpl string, msg *Message) (string, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid %s template %s", name, tmpl)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, msg); err != nil {
+		return "", errors.Wrapf(err, "failed to render %s template %s", name, tmpl)
+	}
+	if b.Len() == 0 {
+		return "", errors.Errorf("%s is empty by template %s", name, tmpl)
+	}
+	return b.String(), nil
+}
+
+func firstNonEmpty(ss ...string) string {
+	for _, s := range ss {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
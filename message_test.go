@@ -3,10 +3,12 @@ package sqsjfr_test
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kayac/sqsjfr"
+	"github.com/robfig/cron/v3"
 )
 
 type testMessage struct {
@@ -26,7 +28,7 @@ func TestNewMessage(t *testing.T) {
 		"FOO": `foo " foo`,
 		"BAR": "bar",
 	}
-	msg, err := sqsjfr.NewMessage(`echo "hello world"`, "tests/message.template", now, envs)
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: `echo "hello world"`}, "tests/message.template", now, time.Minute, envs)
 	if err != nil {
 		t.Error(err)
 	}
@@ -66,7 +68,7 @@ func TestNewMessageNoTemplate(t *testing.T) {
 		"FOO": `foo " foo`,
 		"BAR": "bar",
 	}
-	msg, err := sqsjfr.NewMessage(`echo "hello world"`, "", now, envs)
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: `echo "hello world"`}, "", now, time.Minute, envs)
 	if err != nil {
 		t.Error(err)
 	}
@@ -98,3 +100,94 @@ func TestNewMessageNoTemplate(t *testing.T) {
 		t.Error("duplication id must be changed when invokedAt modified")
 	}
 }
+
+func TestNewMessageTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 10, 7, 11, 22, 33, 123456, loc)
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: `date`}, "", now, time.Minute, map[string]string{})
+	if err != nil {
+		t.Error(err)
+	}
+	if msg.Timezone != "Asia/Tokyo" {
+		t.Errorf("unexpected timezone %s", msg.Timezone)
+	}
+	if msg.InvokedAt != now.Truncate(time.Minute).Unix() {
+		t.Errorf("unexpected invoked_at %d", msg.InvokedAt)
+	}
+}
+
+func TestNewMessageSecondsPrecision(t *testing.T) {
+	now := time.Date(2020, 10, 7, 11, 22, 33, 123456, time.Local)
+	msg1, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: `date`}, "", now, time.Second, map[string]string{})
+	if err != nil {
+		t.Error(err)
+	}
+	if msg1.InvokedAt != now.Truncate(time.Second).Unix() {
+		t.Errorf("unexpected invoked_at %d", msg1.InvokedAt)
+	}
+	msg2, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: `date`}, "", now.Add(15*time.Second), time.Second, map[string]string{})
+	if err != nil {
+		t.Error(err)
+	}
+	if msg1.DeduplicationID() == msg2.DeduplicationID() {
+		t.Error("duplication id must be changed in different seconds")
+	}
+}
+
+func TestDeduplicationIDNotChangedByOtherLines(t *testing.T) {
+	now := time.Date(2020, 10, 7, 11, 22, 0, 0, time.UTC)
+	dedupID := func(crontab string) (int, string) {
+		var job *sqsjfr.Job
+		fn := func(command string) cron.Job {
+			j := &sqsjfr.Job{Command: command}
+			if command == "echo target" {
+				job = j
+			}
+			return j
+		}
+		if _, _, _, err := sqsjfr.ReadCrontab(strings.NewReader(crontab), &sqsjfr.Option{}, fn); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := sqsjfr.NewMessage(job, "", now, time.Minute, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.EntryID, msg.DeduplicationID()
+	}
+	before, id := dedupID("* * * * * echo target\n")
+	after, inserted := dedupID("0 * * * * echo other\n* * * * * echo target\n")
+	if before == after {
+		t.Errorf("entry ID must be the position %d", after)
+	}
+	if id != inserted {
+		t.Errorf("deduplication ID must not be changed by inserting a line %s -> %s", id, inserted)
+	}
+}
+
+func TestDeduplicationIDOfNamedEntry(t *testing.T) {
+	now := time.Date(2020, 10, 7, 11, 22, 0, 0, time.UTC)
+	var jobs []*sqsjfr.Job
+	fn := func(command string) cron.Job {
+		j := &sqsjfr.Job{Command: command}
+		jobs = append(jobs, j)
+		return j
+	}
+	r := strings.NewReader("* * * * * echo target\n[name=named] * * * * * echo target\n")
+	if _, _, _, err := sqsjfr.ReadCrontab(r, &sqsjfr.Option{}, fn); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, j := range jobs {
+		msg, err := sqsjfr.NewMessage(j, "", now, time.Minute, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, msg.DeduplicationID())
+	}
+	if ids[0] == ids[1] {
+		t.Errorf("deduplication IDs of a named entry and an identical unnamed entry must be different %s", ids[0])
+	}
+}
@@ -0,0 +1,125 @@
+package sqsjfr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// metricsWriter writes metrics in Prometheus text exposition format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m *metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(m.w, "# TYPE %s %s\n", name, typ)
+}
+
+// sample writes a sample. labels are pairs of name and value.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteString(",")
+			}
+			fmt.Fprintf(m.w, `%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1]))
+		}
+		m.w.WriteString("}")
+	}
+	m.w.WriteString(" ")
+	m.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.w.WriteString("\n")
+}
+
+func (app *App) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	app.writeMetrics(w)
+}
+
+func (app *App) writeMetrics(w io.Writer) error {
+	m := &metricsWriter{w: bufio.NewWriter(w)}
+	s := app.stats
+	load := func(p *int64) float64 {
+		return float64(atomic.LoadInt64(p))
+	}
+
+	m.header("sqsjfr_invocations_total", "counter", "Number of invocations.")
+	m.sample("sqsjfr_invocations_total", load(&s.Invocations.Succeeded), "result", "succeeded")
+	m.sample("sqsjfr_invocations_total", load(&s.Invocations.Failed), "result", "failed")
+	m.sample("sqsjfr_invocations_total", load(&s.Invocations.Skipped), "result", "skipped")
+	m.sample("sqsjfr_invocations_total", load(&s.Invocations.Deduplicated), "result", "deduplicated")
+
+	keys, entries := s.entrySnapshot()
+	m.header("sqsjfr_entry_invocations_total", "counter", "Number of invocations of the entry.")
+	for _, key := range keys {
+		e := entries[key]
+		m.sample("sqsjfr_entry_invocations_total", load(&e.Succeeded), "entry", key, "result", "succeeded")
+		m.sample("sqsjfr_entry_invocations_total", load(&e.Failed), "entry", key, "result", "failed")
+		m.sample("sqsjfr_entry_invocations_total", load(&e.Skipped), "entry", key, "result", "skipped")
+		m.sample("sqsjfr_entry_invocations_total", load(&e.Deduplicated), "entry", key, "result", "deduplicated")
+	}
+
+	m.header("sqsjfr_send_retries_total", "counter", "Number of retries to send messages.")
+	m.sample("sqsjfr_send_retries_total", load(&s.Invocations.Retried))
+
+	m.header("sqsjfr_entry_send_retries_total", "counter", "Number of retries to send messages of the entry.")
+	for _, key := range keys {
+		m.sample("sqsjfr_entry_send_retries_total", load(&entries[key].Retried), "entry", key)
+	}
+
+	m.header("sqsjfr_entry_kinesis_failed_records_total", "counter", "Number of records of the entry which failed to put to Kinesis.")
+	for _, key := range keys {
+		m.sample("sqsjfr_entry_kinesis_failed_records_total", load(&entries[key].FailedRecords), "entry", key)
+	}
+
+	m.header("sqsjfr_entries_registered", "gauge", "Number of registered entries.")
+	m.sample("sqsjfr_entries_registered", load(&s.Entries.Registered))
+
+	m.header("sqsjfr_crontab_reloads_total", "counter", "Number of crontab reloads.")
+	m.sample("sqsjfr_crontab_reloads_total", load(&s.Crontab.Reloads))
+
+	app.mu.RLock()
+	digest := fmt.Sprintf("%x", app.digest)
+	app.mu.RUnlock()
+	m.header("sqsjfr_crontab_info", "gauge", "Information of the loaded crontab.")
+	m.sample("sqsjfr_crontab_info", 1, "digest", digest)
+
+	m.header("sqsjfr_spool_depth", "gauge", "Number of spooled messages.")
+	m.sample("sqsjfr_spool_depth", load(&s.Spool.Depth))
+	m.header("sqsjfr_spool_resent_total", "counter", "Number of spooled messages resent.")
+	m.sample("sqsjfr_spool_resent_total", load(&s.Spool.Resent))
+	m.header("sqsjfr_spool_dropped_total", "counter", "Number of spooled messages dropped.")
+	m.sample("sqsjfr_spool_dropped_total", load(&s.Spool.Dropped))
+
+	if s.Leader != nil {
+		leader, transitions := s.Leader.snapshot()
+		var v float64
+		if leader {
+			v = 1
+		}
+		m.header("sqsjfr_leader", "gauge", "1 when this process is the leader.")
+		m.sample("sqsjfr_leader", v, "holder", s.Leader.holder)
+		m.header("sqsjfr_leader_transitions_total", "counter", "Number of leadership transitions.")
+		m.sample("sqsjfr_leader_transitions_total", float64(transitions))
+	}
+
+	counts, count, sum := s.sendLatency.snapshot()
+	m.header("sqsjfr_send_duration_seconds", "histogram", "Latency of sending a message to the destination.")
+	for i, le := range latencyBuckets {
+		m.sample("sqsjfr_send_duration_seconds_bucket", float64(counts[i]), "le", strconv.FormatFloat(le, 'g', -1, 64))
+	}
+	m.sample("sqsjfr_send_duration_seconds_bucket", float64(count), "le", "+Inf")
+	m.sample("sqsjfr_send_duration_seconds_sum", sum)
+	m.sample("sqsjfr_send_duration_seconds_count", float64(count))
+
+	return m.w.Flush()
+}
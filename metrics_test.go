@@ -0,0 +1,39 @@
+package sqsjfr_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"AccessDenied"}
+
+	app := newRetryTestApp(ts.URL)
+	msg := newTestMessage(t, ts.URL)
+	msg.EntryName = "nightly-report"
+	app.Send(msg)
+	app.Send(msg)
+
+	var b bytes.Buffer
+	if err := app.WriteMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(b.String())
+	for _, line := range []string{
+		`# TYPE sqsjfr_invocations_total counter`,
+		`sqsjfr_invocations_total{result="succeeded"} 1`,
+		`sqsjfr_invocations_total{result="failed"} 1`,
+		`sqsjfr_entry_invocations_total{entry="nightly-report",result="succeeded"} 1`,
+		`sqsjfr_entry_invocations_total{entry="nightly-report",result="failed"} 1`,
+		`sqsjfr_send_duration_seconds_bucket{le="+Inf"} 2`,
+		`sqsjfr_send_duration_seconds_count 2`,
+		`sqsjfr_crontab_info{digest=""} 1`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("%s is not found in metrics", line)
+		}
+	}
+}
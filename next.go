@@ -0,0 +1,111 @@
+package sqsjfr
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// timeLayouts defines layouts of times accepted by ParseTime.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Preview represents upcoming invocations of an entry.
+type Preview struct {
+	Entry    string  `json:"entry"`
+	Spec     string  `json:"spec"`
+	Timezone string  `json:"timezone"`
+	Command  string  `json:"command"`
+	Fires    []*Fire `json:"fires"`
+}
+
+// Fire represents an invocation of an entry and the message to be sent.
+type Fire struct {
+	Time    time.Time       `json:"time"`
+	Message json.RawMessage `json:"message"`
+}
+
+// ParseTime parses s as RFC3339, or a local time in the timezone of the option.
+func (opt *Option) ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	loc, err := opt.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid time %s", s)
+}
+
+// Next loads the crontab of the option and returns upcoming invocations of entries from from.
+// When to is not zero, returns all invocations before to. Otherwise returns n invocations for each entry.
+func Next(opt *Option, from, to time.Time, n int) ([]*Preview, error) {
+	app, err := newOfflineApp(opt)
+	if err != nil {
+		return nil, err
+	}
+	return app.next(from, to, n)
+}
+
+// newOfflineApp creates an App which loads the crontab but never sends messages.
+func newOfflineApp(opt *Option) (*App, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	app := &App{
+		option: opt,
+		sess:   sess,
+		ctx:    context.Background(),
+		stats:  &Stats{},
+		paused: make(map[string]bool),
+	}
+	if err := app.load(); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func (app *App) next(from, to time.Time, n int) ([]*Preview, error) {
+	var previews []*Preview
+	for _, entry := range app.cron.Entries() {
+		j, ok := entry.Job.(*Job)
+		if !ok {
+			continue
+		}
+		p := &Preview{
+			Entry:    j.String(),
+			Spec:     j.Spec,
+			Timezone: j.Location.String(),
+			Command:  j.Command,
+			Fires:    []*Fire{},
+		}
+		// Next returns a time after the given time, so from is included.
+		for t := entry.Schedule.Next(from.Add(-time.Nanosecond)); !t.IsZero(); t = entry.Schedule.Next(t) {
+			if to.IsZero() && len(p.Fires) >= n || !to.IsZero() && !t.Before(to) {
+				break
+			}
+			msg, err := app.newMessage(j, t)
+			if err != nil {
+				return nil, errors.Wrapf(err, "entry %s at %s", j, t)
+			}
+			p.Fires = append(p.Fires, &Fire{
+				Time:    t.In(j.Location),
+				Message: json.RawMessage(msg.String()),
+			})
+		}
+		previews = append(previews, p)
+	}
+	return previews, nil
+}
@@ -0,0 +1,64 @@
+package sqsjfr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestNext(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.tz",
+		Timezone:   "UTC",
+	})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2020, 10, 7, 0, 0, 0, 0, time.UTC)
+	previews, err := app.Next(from, time.Time{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previews) != 3 {
+		t.Fatalf("unexpected previews len %d", len(previews))
+	}
+	expected := [][]string{
+		{"2020-10-07T00:00:00Z", "2020-10-07T00:01:00Z"},
+		{"2020-10-07T09:00:00+09:00", "2020-10-08T09:00:00+09:00"},
+		{"2020-10-07T09:00:00-04:00", "2020-10-08T09:00:00-04:00"},
+	}
+	for i, p := range previews {
+		if len(p.Fires) != 2 {
+			t.Fatalf("unexpected fires len %d of %s", len(p.Fires), p.Entry)
+		}
+		for k, f := range p.Fires {
+			if s := f.Time.Format(time.RFC3339); s != expected[i][k] {
+				t.Errorf("unexpected fire time %s expected %s", s, expected[i][k])
+			}
+		}
+	}
+	if m := string(previews[1].Fires[0].Message); m != `{"command":"echo tokyo","invoked_at":1602028800,"entry_id":2,"entry_key":"4dc9b3a1afd4185f","envs":{},"timezone":"Asia/Tokyo"}` {
+		t.Errorf("unexpected message %s", m)
+	}
+}
+
+func TestNextRange(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.tz",
+		Timezone:   "UTC",
+	})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2020, 10, 7, 0, 0, 0, 0, time.UTC)
+	previews, err := app.Next(from, from.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range []int{60, 1, 0} {
+		if len(previews[i].Fires) != n {
+			t.Errorf("unexpected fires len %d of %s expected %d", len(previews[i].Fires), previews[i].Entry, n)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
 )
 
 const DefaultStatsServerPort = 8061
@@ -22,31 +23,107 @@ const DefaultStatsServerPort = 8061
 type Option struct {
 	CrontabURL      string
 	QueueURL        string
+	Destination     string
 	MessageTemplate string
+	EventSource     string
+	EventDetailType string
+	PartitionKey    string
 	CheckInterval   time.Duration
 	DryRun          bool
 	StatsPort       int
+	Timezone        string
+	Seconds         bool
+	ScopedEnv       bool
+	StateURL        string
+	CatchUp         string
+	CatchUpMax      int
+	SpoolDir        string
+	SpoolMaxAge     time.Duration
+
+	AllowStandardQueue bool
+	DedupURL           string
+	DedupTTL           time.Duration
+
+	LeaseURL      string
+	LeaseDuration time.Duration
+
+	SendMaxAttempts    int
+	SendInitialBackoff time.Duration
+	SendMaxBackoff     time.Duration
+	SendJitter         float64
+
+	HTTPHeaders []string
+	HTTPTimeout time.Duration
+	HTTPSecret  string
+
+	AdminToken string
+
+	// Sender sends messages instead of -destination and -queue-url when it is set by a program which embeds sqsjfr.
+	// It is closed on shutdown when it implements io.Closer.
+	Sender Sender
 
 	sess *session.Session
 }
 
 // Validate validates option values.
 func (opt *Option) Validate() error {
-	region, accountID, queueName, err := parseQueueURL(opt.QueueURL)
-	log.Println("[debug] region:", region)
-	log.Println("[debug] accountID:", accountID)
-	log.Println("[debug] queueName:", queueName)
-	if err != nil {
+	if opt.Sender != nil {
+		if opt.Destination != "" {
+			return errors.Errorf("-destination %s conflicts with the sender", opt.Destination)
+		}
+	} else if opt.Destination == "" {
+		if err := validateQueueURL(opt.QueueURL, !opt.AllowStandardQueue); err != nil {
+			return err
+		}
+	} else {
+		u, err := parseDestination(opt.Destination, !opt.AllowStandardQueue)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "sqs" && opt.QueueURL != "" && opt.QueueURL != sqsQueueURL(u) {
+			return errors.Errorf("-queue-url %s conflicts with -destination %s", opt.QueueURL, opt.Destination)
+		}
+	}
+
+	if opt.DedupURL != "" {
+		if _, _, err := parseDedupURL(opt.DedupURL); err != nil {
+			return err
+		}
+	} else if opt.AllowStandardQueue {
+		log.Println("[warn] messages may be sent more than once by multiple processes without -dedup-url")
+	}
+	if opt.LeaseURL != "" {
+		if _, _, _, err := parseLeaseURL(opt.LeaseURL); err != nil {
+			return err
+		}
+		if opt.LeaseDuration != 0 && opt.LeaseDuration < MinLeaseDuration {
+			return errors.Errorf("lease duration must be %s or longer", MinLeaseDuration)
+		}
+	}
+	if _, err := parseHTTPHeaders(opt.HTTPHeaders); err != nil {
+		return err
+	}
+	if _, err := opt.location(); err != nil {
 		return err
 	}
-	if !strings.HasSuffix(queueName, ".fifo") {
-		return errors.New("FIFO queue is required")
+	if opt.SendJitter < 0 || opt.SendJitter > 1 {
+		return errors.Errorf("jitter must be between 0 and 1")
+	}
+	switch opt.CatchUp {
+	case "", CatchUpNone:
+	case CatchUpOnce, CatchUpAll:
+		if opt.StateURL == "" {
+			return errors.Errorf("-state-url is required for catch-up policy %s", opt.CatchUp)
+		}
+	default:
+		return errors.Errorf("invalid catch-up policy %s", opt.CatchUp)
 	}
 
 	msg, err := newMessage(
-		`echo "hello world!"`,
+		&Job{Command: `echo "hello world!"`},
 		opt.MessageTemplate,
 		time.Now(),
+		opt.precision(),
 		Environments(map[string]string{}),
 	)
 	if err != nil {
@@ -54,6 +131,117 @@ func (opt *Option) Validate() error {
 	}
 	log.Println("[debug] generated message on validate", msg.String())
 
+	// templates of fields of the destination
+	var fields map[string]string
+	switch opt.destinationScheme() {
+	case "eventbridge":
+		fields = map[string]string{"source": opt.EventSource, "detail-type": opt.EventDetailType}
+	case "kinesis":
+		fields = map[string]string{"partition-key": opt.PartitionKey}
+	}
+	for name, tmpl := range fields {
+		if tmpl == "" {
+			continue
+		}
+		if err := checkTemplateField(name, tmpl); err != nil {
+			return err
+		}
+	}
+	if opt.destinationScheme() == "lambda" {
+		if _, err := lambdaPayload(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// destinationScheme returns the scheme of the destination. The default is sqs, and empty for a custom sender.
+func (opt *Option) destinationScheme() string {
+	if opt.Sender != nil {
+		return ""
+	}
+	if opt.Destination == "" {
+		return "sqs"
+	}
+	if i := strings.Index(opt.Destination, "://"); i > 0 {
+		return opt.Destination[:i]
+	}
+	return ""
+}
+
+// queueURL returns the default queue URL of entries.
+// The queue of a sqs:// destination is the default when -queue-url is not specified.
+func (opt *Option) queueURL() string {
+	if opt.QueueURL != "" || opt.destinationScheme() != "sqs" || opt.Destination == "" {
+		return opt.QueueURL
+	}
+	u, err := parseDestination(opt.Destination, false)
+	if err != nil {
+		return ""
+	}
+	return sqsQueueURL(u)
+}
+
+// sendTimeout returns a timeout to send a message to the destination.
+func (opt *Option) sendTimeout() time.Duration {
+	switch opt.destinationScheme() {
+	case "http", "https":
+		if opt.HTTPTimeout > 0 {
+			return opt.HTTPTimeout
+		}
+	}
+	return SQSTimeout
+}
+
+// location returns the default location for schedules in crontab.
+func (opt *Option) location() (*time.Location, error) {
+	if opt.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(opt.Timezone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid timezone %s", opt.Timezone)
+	}
+	return loc, nil
+}
+
+// parser returns a parser for schedule specs in crontab.
+func (opt *Option) parser() cron.Parser {
+	if opt.Seconds {
+		return cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	}
+	return cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+}
+
+// leaseDuration returns a duration of the leader lease.
+func (opt *Option) leaseDuration() time.Duration {
+	if opt.LeaseDuration == 0 {
+		return DefaultLeaseDuration
+	}
+	return opt.LeaseDuration
+}
+
+// precision returns a precision of InvokedAt of messages.
+func (opt *Option) precision() time.Duration {
+	if opt.Seconds {
+		return time.Second
+	}
+	return time.Minute
+}
+
+// validateQueueURL validates the queue URL. When requireFIFO is true, standard queues are not allowed.
+func validateQueueURL(s string, requireFIFO bool) error {
+	region, accountID, queueName, err := parseQueueURL(s)
+	log.Println("[debug] region:", region)
+	log.Println("[debug] accountID:", accountID)
+	log.Println("[debug] queueName:", queueName)
+	if err != nil {
+		return err
+	}
+	if requireFIFO && !isFIFOQueue(queueName) {
+		return errors.New("FIFO queue is required (use -allow-standard-queue for standard queues)")
+	}
 	return nil
 }
 
@@ -73,14 +261,19 @@ func parseQueueURL(s string) (region string, accountID string, queueName string,
 	p := strings.SplitN(u.Path, "/", 3)
 	if len(p) != 3 {
 		err = fmt.Errorf("invalid queue URL:%s", s)
+		return
 	}
 	accountID, queueName = p[1], p[2]
 	return
 }
 
 func (app *App) ReadCrontabFile() (io.ReadCloser, error) {
-	log.Println("[debug] crontab URL:", app.option.CrontabURL)
-	u, err := url.Parse(app.option.CrontabURL)
+	return readCrontabFile(app.option.CrontabURL, app.sess)
+}
+
+func readCrontabFile(crontabURL string, sess *session.Session) (io.ReadCloser, error) {
+	log.Println("[debug] crontab URL:", crontabURL)
+	u, err := url.Parse(crontabURL)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +282,7 @@ func (app *App) ReadCrontabFile() (io.ReadCloser, error) {
 	switch u.Scheme {
 	case "s3":
 		key := strings.TrimPrefix(u.Path, "/")
-		src, err = readS3(app.sess, u.Host, key)
+		src, err = readS3(sess, u.Host, key)
 	case "http", "https":
 		src, err = readHTTP(u.String())
 	case "file", "":
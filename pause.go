@@ -0,0 +1,58 @@
+package sqsjfr
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// isPaused reports whether the job is paused. Skipped invocations are counted in stats.
+func (app *App) isPaused(j *Job) bool {
+	key := j.String()
+	app.pausedMu.RLock()
+	paused := app.paused[key]
+	app.pausedMu.RUnlock()
+	if paused {
+		atomic.AddInt64(&app.stats.Invocations.Skipped, 1)
+		atomic.AddInt64(&app.stats.entry(key).Skipped, 1)
+	}
+	return paused
+}
+
+// setPaused pauses or resumes the job. Paused state is persisted when the state store is available.
+func (app *App) setPaused(j *Job, paused bool) {
+	key := j.String()
+	app.pausedMu.Lock()
+	if paused {
+		app.paused[key] = true
+	} else {
+		delete(app.paused, key)
+	}
+	if app.state != nil {
+		app.state.SetPaused(key, paused)
+	}
+	app.pausedMu.Unlock()
+	if paused {
+		log.Printf("[info] [entry:%s] paused", j)
+	} else {
+		log.Printf("[info] [entry:%s] resumed", j)
+	}
+}
+
+// applyPausedState pauses or resumes entries by the state, which may be modified by other processes.
+func (app *App) applyPausedState() {
+	paused := make(map[string]bool)
+	app.pausedMu.Lock()
+	defer app.pausedMu.Unlock()
+	for _, key := range app.state.PausedEntries() {
+		paused[key] = true
+		if !app.paused[key] {
+			log.Printf("[info] [entry:%s] paused by the state", key)
+		}
+	}
+	for key := range app.paused {
+		if !paused[key] {
+			log.Printf("[info] [entry:%s] resumed by the state", key)
+		}
+	}
+	app.paused = paused
+}
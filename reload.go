@@ -0,0 +1,132 @@
+package sqsjfr
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"sync/atomic"
+
+	"github.com/robfig/cron/v3"
+)
+
+// reload applies a modified crontab to the running scheduler.
+// Unchanged entries are kept as is, so the schedules of them are not affected by reloading.
+func (app *App) reload(c *cron.Cron, envs Environments, digest []byte) {
+	current, currentKeys := jobsByKey(app.cron.Entries())
+	next, nextKeys := jobsByKey(c.Entries())
+
+	var added, updated, removed int
+	for _, key := range currentKeys {
+		cur := current[key]
+		j, ok := next[key]
+		if !ok {
+			app.cron.Remove(cur.job.ID)
+			app.stats.removeEntry(key)
+			log.Printf("[info] [entry:%s] removed > %s %s", cur.job, cur.job.Spec, cur.job.Command)
+			removed++
+			continue
+		}
+		diff := cur.job.diff(j.job)
+		if len(diff) == 0 {
+			// the position may be moved by other entries
+			atomic.StoreInt64(&cur.job.index, j.job.index)
+			continue
+		}
+		app.cron.Remove(cur.job.ID)
+		j.job.ID = app.cron.Schedule(j.schedule, j.job)
+		for _, d := range diff {
+			log.Printf("[info] [entry:%s] updated %s", j.job, d)
+		}
+		updated++
+	}
+	for _, key := range nextKeys {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		j := next[key]
+		j.job.ID = app.cron.Schedule(j.schedule, j.job)
+		log.Printf("[info] [entry:%s] added (%s) > %s %s", j.job, j.job.Location, j.job.Spec, j.job.Command)
+		added++
+	}
+
+	app.mu.Lock()
+	for _, d := range diffEnvs(app.envs, envs) {
+		log.Printf("[info] environment variable %s", d)
+	}
+	app.envs = envs
+	app.digest = digest
+	app.mu.Unlock()
+
+	log.Printf("[info] crontab reloaded: %d added, %d updated, %d removed", added, updated, removed)
+	atomic.StoreInt64(&app.stats.Entries.Registered, int64(len(app.cron.Entries())))
+	atomic.AddInt64(&app.stats.Crontab.Reloads, 1)
+}
+
+type scheduledJob struct {
+	job      *Job
+	schedule cron.Schedule
+}
+
+// jobsByKey returns jobs keyed by the names (or stable IDs), and keys ordered by the position in crontab.
+func jobsByKey(entries []cron.Entry) (map[string]scheduledJob, []string) {
+	var jobs []scheduledJob
+	for _, entry := range entries {
+		if j, ok := entry.Job.(*Job); ok {
+			jobs = append(jobs, scheduledJob{job: j, schedule: entry.Schedule})
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].job.index < jobs[b].job.index
+	})
+	m := make(map[string]scheduledJob, len(jobs))
+	keys := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		key := j.job.String()
+		m[key] = j
+		keys = append(keys, key)
+	}
+	return m, keys
+}
+
+// diff returns human-readable differences between the jobs.
+func (j *Job) diff(n *Job) []string {
+	var diff []string
+	add := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			diff = append(diff, fmt.Sprintf("%s: %v -> %v", name, a, b))
+		}
+	}
+	add("schedule", j.Spec, n.Spec)
+	add("timezone", j.Location.String(), n.Location.String())
+	add("command", j.Command, n.Command)
+	add("queue", j.QueueURL, n.QueueURL)
+	add("group", j.MessageGroupID, n.MessageGroupID)
+	add("delay", j.Delay, n.Delay)
+	add("template", j.MessageTemplate, n.MessageTemplate)
+	add("source", j.EventSource, n.EventSource)
+	add("detail-type", j.EventDetailType, n.EventDetailType)
+	add("partition-key", j.PartitionKey, n.PartitionKey)
+	add("function", j.FunctionARN, n.FunctionARN)
+	add("envs", j.Env, n.Env)
+	return diff
+}
+
+// diffEnvs returns human-readable differences between the environment variables.
+func diffEnvs(a, b Environments) []string {
+	var diff []string
+	for name, value := range b {
+		if old, ok := a[name]; !ok {
+			diff = append(diff, fmt.Sprintf("added %s=%s", name, value))
+		} else if old != value {
+			diff = append(diff, fmt.Sprintf("updated %s=%s -> %s", name, old, value))
+		}
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			diff = append(diff, fmt.Sprintf("removed %s", name))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
@@ -0,0 +1,104 @@
+package sqsjfr_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestReloadInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqsjfr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "crontab")
+
+	before := `FOO=foo
+# name: updated
+0 * * * * echo updated
+* * * * * echo unchanged
+* * * * * echo removed
+`
+	after := `FOO=bar
+# name: updated
+30 * * * * echo updated
+* * * * * echo added
+* * * * * echo unchanged
+`
+	if err := ioutil.WriteFile(path, []byte(before), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{CrontabURL: path})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]int)
+	var removedKey string
+	for _, entry := range app.Entries() {
+		j := entry.Job.(*sqsjfr.Job)
+		ids[j.Command] = int(entry.ID)
+		if j.Command == "echo removed" {
+			removedKey = j.String()
+		}
+	}
+	app.Stats().EntryStats(removedKey).Succeeded = 1
+
+	if err := ioutil.WriteFile(path, []byte(after), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.CheckCrontab(); err != nil {
+		t.Fatal(err)
+	}
+	entries := app.Entries()
+	if len(entries) != 3 {
+		t.Errorf("unexpected entries len %d", len(entries))
+	}
+	for _, entry := range entries {
+		j := entry.Job.(*sqsjfr.Job)
+		switch j.Command {
+		case "echo unchanged":
+			if int(entry.ID) != ids[j.Command] {
+				t.Errorf("unchanged entry must be kept %d -> %d", ids[j.Command], entry.ID)
+			}
+			// renumbered by the position in the new crontab
+			msg, err := app.NewMessage(j, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.EntryID != 3 {
+				t.Errorf("unexpected entry ID %d of the unchanged entry", msg.EntryID)
+			}
+		case "echo updated":
+			if int(entry.ID) == ids[j.Command] || j.Spec != "30 * * * *" {
+				t.Errorf("unexpected updated entry %d %#v", entry.ID, j)
+			}
+		case "echo added":
+			if _, ok := ids[j.Command]; ok {
+				t.Errorf("unexpected added entry %#v", j)
+			}
+		default:
+			t.Errorf("unexpected entry %#v", j)
+		}
+		if int(entry.ID) != int(j.ID) {
+			t.Errorf("unexpected job ID %d of entry %d", j.ID, entry.ID)
+		}
+	}
+	// stats of the removed entry are dropped
+	var buf bytes.Buffer
+	if err := app.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), removedKey) {
+		t.Errorf("stats of the removed entry %s must be dropped", removedKey)
+	}
+	if app.Envs()["FOO"] != "bar" {
+		t.Errorf("unexpected envs %s", app.Envs())
+	}
+}
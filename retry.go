@@ -0,0 +1,98 @@
+package sqsjfr
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Default values of the retry policy to send messages.
+const (
+	DefaultSendMaxAttempts    = 3
+	DefaultSendInitialBackoff = 200 * time.Millisecond
+	DefaultSendMaxBackoff     = 5 * time.Second
+	DefaultSendJitter         = 0.2
+)
+
+// nonRetryableCodes defines error codes which never succeed by retrying.
+var nonRetryableCodes = map[string]bool{
+	sqs.ErrCodeQueueDoesNotExist:                 true,
+	sqs.ErrCodeInvalidMessageContents:            true,
+	sqs.ErrCodeUnsupportedOperation:              true,
+	sns.ErrCodeNotFoundException:                 true,
+	sns.ErrCodeAuthorizationErrorException:       true,
+	sns.ErrCodeInvalidParameterException:         true,
+	kinesis.ErrCodeResourceNotFoundException:     true,
+	kinesis.ErrCodeInvalidArgumentException:      true,
+	lambda.ErrCodeInvalidRequestContentException: true,
+	lambda.ErrCodeRequestTooLargeException:       true,
+	lambda.ErrCodeUnsupportedMediaTypeException:  true,
+	"AccessDenied":              true,
+	"AccessDeniedException":     true,
+	"InvalidClientTokenId":      true,
+	"InvalidParameterValue":     true,
+	"MissingParameter":          true,
+	"KMS.AccessDeniedException": true,
+}
+
+// isRetryable reports whether the error may be resolved by retrying.
+func isRetryable(err error) bool {
+	if err == context.Canceled {
+		return false
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return !nonRetryableCodes[aerr.Code()]
+	}
+	if herr, ok := err.(*httpError); ok {
+		return herr.retryable()
+	}
+	return true
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// withJitter reduces the duration randomly by the jitter ratio.
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	jitterMu.Lock()
+	f := jitterRand.Float64()
+	jitterMu.Unlock()
+	return d - time.Duration(float64(d)*jitter*f)
+}
+
+// sendWithRetry sends the message by the retry policy.
+func (app *App) sendWithRetry(msg *Message) error {
+	opt := app.option
+	for attempt := 1; ; attempt++ {
+		err := app.sendMessage(msg)
+		if err == nil {
+			return nil
+		}
+		if attempt >= opt.SendMaxAttempts || !isRetryable(err) {
+			return err
+		}
+		wait := withJitter(backoff(opt.SendInitialBackoff, opt.SendMaxBackoff, attempt), opt.SendJitter)
+		log.Printf("[warn] [entry:%s] failed to send message (attempt %d/%d), retrying in %s: %s", msg.entry(), attempt, opt.SendMaxAttempts, wait, err)
+		atomic.AddInt64(&app.stats.Invocations.Retried, 1)
+		atomic.AddInt64(&app.stats.entry(msg.entry()).Retried, 1)
+		select {
+		case <-app.ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
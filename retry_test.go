@@ -0,0 +1,120 @@
+package sqsjfr_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func newRetryTestApp(endpoint string) *sqsjfr.App {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		SendMaxAttempts:    3,
+		SendInitialBackoff: time.Millisecond,
+		SendMaxBackoff:     10 * time.Millisecond,
+		SendJitter:         0.5,
+	})
+	app.SetSQSEndpoint(endpoint)
+	return app
+}
+
+func newTestMessage(t *testing.T, endpoint string) *sqsjfr.Message {
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: "date"}, "", time.Now(), time.Minute, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.QueueURL = endpoint + "/123456789012/test.fifo"
+	msg.MessageGroupID = sqsjfr.DefaultMessageGroupID
+	return msg
+}
+
+func TestSendRetry(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"ServiceUnavailable", "ThrottlingException"}
+
+	app := newRetryTestApp(ts.URL)
+	msg := newTestMessage(t, ts.URL)
+	if err := app.Send(msg); err != nil {
+		t.Error(err)
+	}
+	if len(f.received) != 1 {
+		t.Errorf("unexpected received len %d", len(f.received))
+	}
+	if r := f.received[0]; r["MessageDeduplicationId"] != msg.DeduplicationID() || r["MessageBody"] != msg.String() {
+		t.Errorf("unexpected received message %v", r)
+	}
+	stats := app.Stats()
+	if stats.Invocations.Succeeded != 1 || stats.Invocations.Failed != 0 || stats.Invocations.Retried != 2 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+}
+
+func TestSendRetryTimeout(t *testing.T) {
+	defer func(d time.Duration) { sqsjfr.SQSTimeout = d }(sqsjfr.SQSTimeout)
+	sqsjfr.SQSTimeout = 500 * time.Millisecond
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	var mu sync.Mutex
+	requests := 0
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n := requests
+		mu.Unlock()
+		if n == 1 {
+			// the first attempt times out
+			time.Sleep(time.Second)
+		}
+		f.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+
+	app := newRetryTestApp(slow.URL)
+	if err := app.Send(newTestMessage(t, slow.URL)); err != nil {
+		t.Error(err)
+	}
+	stats := app.Stats()
+	if stats.Invocations.Succeeded != 1 || stats.Invocations.Retried != 1 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+	if requests != 2 {
+		t.Errorf("unexpected requests %d", requests)
+	}
+}
+
+func TestSendRetryGiveUp(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"ServiceUnavailable", "ServiceUnavailable", "ServiceUnavailable", "ServiceUnavailable"}
+
+	app := newRetryTestApp(ts.URL)
+	if err := app.Send(newTestMessage(t, ts.URL)); err == nil {
+		t.Error("must be failed")
+	}
+	stats := app.Stats()
+	if stats.Invocations.Succeeded != 0 || stats.Invocations.Failed != 1 || stats.Invocations.Retried != 2 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+	if len(f.errors) != 1 {
+		t.Errorf("unexpected attempts %d", 4-len(f.errors))
+	}
+}
+
+func TestSendNonRetryable(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"AWS.SimpleQueueService.NonExistentQueue"}
+
+	app := newRetryTestApp(ts.URL)
+	if err := app.Send(newTestMessage(t, ts.URL)); err == nil {
+		t.Error("must be failed")
+	}
+	stats := app.Stats()
+	if stats.Invocations.Failed != 1 || stats.Invocations.Retried != 0 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+}
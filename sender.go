@@ -0,0 +1,135 @@
+package sqsjfr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/pkg/errors"
+)
+
+// Sender sends messages to a destination.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// parseDestination parses a destination URL.
+// ARNs are not valid URL hosts, so sns://, eventbridge://, kinesis:// and lambda:// followed by ARNs are parsed by the prefix.
+// When requireFIFO is true, SQS standard queues are not allowed.
+func parseDestination(s string, requireFIFO bool) (*url.URL, error) {
+	if topicARN := strings.TrimPrefix(s, "sns://"); topicARN != s {
+		if _, err := parseTopicARN(topicARN); err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "sns", Opaque: topicARN}, nil
+	}
+	if busARN := strings.TrimPrefix(s, "eventbridge://"); busARN != s {
+		if _, err := parseEventBusARN(busARN); err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "eventbridge", Opaque: busARN}, nil
+	}
+	if streamARN := strings.TrimPrefix(s, "kinesis://"); streamARN != s {
+		if _, err := parseStreamARN(streamARN); err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "kinesis", Opaque: streamARN}, nil
+	}
+	if functionARN := strings.TrimPrefix(s, "lambda://"); functionARN != s {
+		if _, err := parseFunctionARN(functionARN); err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: "lambda", Opaque: functionARN}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid destination %s", s)
+	}
+	switch u.Scheme {
+	case "sqs":
+		if err := validateQueueURL(sqsQueueURL(u), requireFIFO); err != nil {
+			return nil, err
+		}
+	case "http", "https":
+		if u.Host == "" {
+			return nil, errors.Errorf("invalid destination %s: host is required", s)
+		}
+	case "file":
+		if u.Path == "" {
+			return nil, errors.Errorf("invalid destination %s: path is required", s)
+		}
+	case "stdout":
+	default:
+		return nil, errors.Errorf("destination scheme %s is not supported", u.Scheme)
+	}
+	return u, nil
+}
+
+// newSender creates a Sender for the destination of the option. The default is SQS.
+func newSender(opt *Option, sess *session.Session, stats *Stats) (Sender, error) {
+	if opt.Destination == "" {
+		return newSQSSender(sess), nil
+	}
+	u, err := parseDestination(opt.Destination, !opt.AllowStandardQueue)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "sqs":
+		return newSQSSender(sess), nil
+	case "sns":
+		return newSNSSender(sess, u.Opaque)
+	case "eventbridge":
+		return newEventBridgeSender(sess, u.Opaque)
+	case "kinesis":
+		return newKinesisSender(sess, u.Opaque, stats)
+	case "lambda":
+		return newLambdaSender(sess, u.Opaque)
+	case "http", "https":
+		return newHTTPSender(u.String(), opt)
+	case "file":
+		f, err := os.OpenFile(u.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s := newWriterSender(f)
+		s.closer = f
+		return s, nil
+	case "stdout":
+		return newWriterSender(os.Stdout), nil
+	}
+	return nil, errors.Errorf("destination scheme %s is not supported", u.Scheme)
+}
+
+// writerSender writes messages as JSON lines.
+type writerSender struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // closes the file of file:// destination
+}
+
+func newWriterSender(w io.Writer) *writerSender {
+	return &writerSender{w: w}
+}
+
+func (s *writerSender) Send(ctx context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintln(s.w, msg.String())
+	return err
+}
+
+// Close closes the file of the destination.
+func (s *writerSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,149 @@
+package sqsjfr_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestSendFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqsjfr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "messages.jsonl")
+	app := newRetryTestApp("")
+	if err := app.SetDestination("file://" + path); err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestMessage(t, "")
+	for i := 0; i < 2; i++ {
+		if err := app.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); s != strings.Repeat(msg.String()+"\n", 2) {
+		t.Errorf("unexpected file content %s", s)
+	}
+	// the file is closed on shutdown
+	app.CloseSender()
+	if err := app.Send(msg); err == nil {
+		t.Error("sending to the closed file must be failed")
+	}
+}
+
+func TestSendSNS(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"Throttling"}
+
+	app := newRetryTestApp(ts.URL)
+	topicARN := "arn:aws:sns:ap-northeast-1:123456789012:test.fifo"
+	if err := app.SetDestination("sns://" + topicARN); err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestMessage(t, "")
+	if err := app.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.received) != 1 {
+		t.Fatalf("unexpected received %d", len(f.received))
+	}
+	r := f.received[0]
+	if r["TopicArn"] != topicARN || r["Message"] != msg.String() ||
+		r["MessageDeduplicationId"] != msg.DeduplicationID() || r["MessageGroupId"] != sqsjfr.DefaultMessageGroupID {
+		t.Errorf("unexpected publish %#v", r)
+	}
+}
+
+func TestValidateDestination(t *testing.T) {
+	for _, dest := range []string{
+		"ftp://example.com/",
+		"http:///path",
+		"sqs://sqs.ap-northeast-1.amazonaws.com/123456789012/standard",
+		"sns://arn:aws:sns:ap-northeast-1:123456789012:standard",
+		"sns://arn:aws:sqs:ap-northeast-1:123456789012:test.fifo",
+		"sns://test.fifo",
+	} {
+		opt := &sqsjfr.Option{Destination: dest}
+		if err := opt.Validate(); err == nil {
+			t.Errorf("destination %s must be invalid", dest)
+		}
+	}
+	opt := &sqsjfr.Option{Destination: "sqs://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo"}
+	if err := opt.Validate(); err != nil {
+		t.Error(err)
+	}
+	if opt.QueueURL != "" {
+		t.Errorf("option must not be modified by Validate %s", opt.QueueURL)
+	}
+	// the queue of the destination is the default queue
+	app := sqsjfr.NewTestApp(opt)
+	msg, err := app.NewMessage(&sqsjfr.Job{Command: "date", Location: time.UTC}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.QueueURL != "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo" {
+		t.Errorf("unexpected queue URL %s", msg.QueueURL)
+	}
+}
+
+type testSender struct {
+	mu       sync.Mutex
+	received []*sqsjfr.Message
+	closed   bool
+}
+
+func (s *testSender) Send(ctx context.Context, msg *sqsjfr.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, msg)
+	return nil
+}
+
+func (s *testSender) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCustomSender(t *testing.T) {
+	s := &testSender{}
+	app, err := sqsjfr.New(context.Background(), &sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		Sender:     s,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	app.Entries()[0].Job.(*sqsjfr.Job).Invoke(time.Now())
+	if len(s.received) != 1 {
+		t.Fatalf("unexpected received %d", len(s.received))
+	}
+	if msg := s.received[0]; msg.DelaySeconds != 0 || msg.QueueURL != "" {
+		t.Errorf("unexpected message %#v", msg)
+	}
+	app.CloseSender()
+	if !s.closed {
+		t.Error("sender must be closed")
+	}
+
+	opt := &sqsjfr.Option{Destination: "stdout://", Sender: s}
+	if err := opt.Validate(); err == nil {
+		t.Error("destination must conflict with the sender")
+	}
+}
@@ -0,0 +1,101 @@
+package sqsjfr
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// DeduplicationInterval defines the deduplication interval of SQS FIFO queues.
+const DeduplicationInterval = 5 * time.Minute
+
+// SimulatedMessage represents a message which would be sent in a simulation.
+type SimulatedMessage struct {
+	Time            time.Time       `json:"time"`
+	Entry           string          `json:"entry"`
+	QueueURL        string          `json:"queue_url,omitempty"`
+	MessageGroupID  string          `json:"message_group_id"`
+	DeduplicationID string          `json:"deduplication_id"`
+	Message         json.RawMessage `json:"message"`
+}
+
+// Simulate loads the crontab of the option and writes messages which would be sent from from to to as JSON lines.
+// Messages are never sent to SQS.
+func Simulate(opt *Option, from, to time.Time, w io.Writer) error {
+	app, err := newOfflineApp(opt)
+	if err != nil {
+		return err
+	}
+	return app.simulate(from, to, w)
+}
+
+// simulate runs the scheduler on a virtual clock from from (inclusive) to to (exclusive).
+func (app *App) simulate(from, to time.Time, w io.Writer) error {
+	type timer struct {
+		schedule cron.Schedule
+		job      *Job
+		next     time.Time
+	}
+	var timers []*timer
+	for _, entry := range app.cron.Entries() {
+		j, ok := entry.Job.(*Job)
+		if !ok {
+			continue
+		}
+		timers = append(timers, &timer{
+			schedule: entry.Schedule,
+			job:      j,
+			next:     entry.Schedule.Next(from.Add(-time.Nanosecond)),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	sent := make(map[string]time.Time) // deduplication ID -> time
+	var count int
+	for {
+		// advances the clock to the earliest invocation
+		var now time.Time
+		for _, t := range timers {
+			if !t.next.IsZero() && (now.IsZero() || t.next.Before(now)) {
+				now = t.next
+			}
+		}
+		if now.IsZero() || !now.Before(to) {
+			break
+		}
+		for _, t := range timers {
+			if !t.next.Equal(now) {
+				continue
+			}
+			t.next = t.schedule.Next(now)
+			j := t.job
+			msg, err := app.newMessage(j, now)
+			if err != nil {
+				return errors.Wrapf(err, "entry %s at %s", j, now)
+			}
+			id := msg.DeduplicationID()
+			if last, ok := sent[id]; ok && now.Sub(last) < DeduplicationInterval {
+				log.Printf("[warn] [entry:%s] message at %s would be deduplicated with the message at %s", j, now, last)
+			}
+			sent[id] = now
+			err = enc.Encode(&SimulatedMessage{
+				Time:            now.In(j.Location),
+				Entry:           j.String(),
+				QueueURL:        msg.QueueURL,
+				MessageGroupID:  msg.MessageGroupID,
+				DeduplicationID: id,
+				Message:         json.RawMessage(msg.String()),
+			})
+			if err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	log.Printf("[info] %d messages simulated from %s to %s", count, from, to)
+	return nil
+}
@@ -0,0 +1,64 @@
+package sqsjfr_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestSimulate(t *testing.T) {
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		Timezone:   "UTC",
+	})
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 12, 31, 23, 58, 0, 0, time.UTC)
+	to := time.Date(2027, 1, 1, 0, 1, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := app.Simulate(from, to, &buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"2026-12-31T23:58:00Z 2142ca414ddbb20b",
+		"2026-12-31T23:59:00Z 2142ca414ddbb20b",
+		"2027-01-01T00:00:00Z hourly-sync",
+		"2027-01-01T00:00:00Z 2142ca414ddbb20b",
+	}
+	dec := json.NewDecoder(&buf)
+	ids := make(map[string]bool)
+	for i := 0; dec.More(); i++ {
+		var m sqsjfr.SimulatedMessage
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) {
+			t.Fatalf("too many messages %#v", m)
+		}
+		if s := m.Time.Format(time.RFC3339) + " " + m.Entry; s != expected[i] {
+			t.Errorf("unexpected message %s expected %s", s, expected[i])
+		}
+		if m.DeduplicationID == "" || ids[m.DeduplicationID] {
+			t.Errorf("unexpected deduplication ID %s", m.DeduplicationID)
+		}
+		ids[m.DeduplicationID] = true
+		if m.QueueURL != "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo" || m.MessageGroupID != sqsjfr.DefaultMessageGroupID {
+			t.Errorf("unexpected destination %#v", m)
+		}
+		var msg sqsjfr.Message
+		if err := json.Unmarshal(m.Message, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if !time.Unix(msg.InvokedAt, 0).Equal(m.Time) {
+			t.Errorf("unexpected invoked_at %d", msg.InvokedAt)
+		}
+	}
+	if len(ids) != len(expected) {
+		t.Errorf("unexpected messages %d", len(ids))
+	}
+}
@@ -0,0 +1,61 @@
+package sqsjfr
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/pkg/errors"
+)
+
+// snsSender publishes messages to a SNS FIFO topic.
+type snsSender struct {
+	svc      *sns.SNS
+	topicARN string
+}
+
+func newSNSSender(sess *session.Session, topicARN string) (*snsSender, error) {
+	a, err := parseTopicARN(topicARN)
+	if err != nil {
+		return nil, err
+	}
+	return &snsSender{
+		svc:      sns.New(sess, aws.NewConfig().WithRegion(a.Region).WithMaxRetries(0)), // retried by sendWithRetry
+		topicARN: topicARN,
+	}, nil
+}
+
+func (s *snsSender) Send(ctx context.Context, msg *Message) error {
+	in := &sns.PublishInput{
+		TopicArn:               aws.String(s.topicARN),
+		Message:                aws.String(msg.String()),
+		MessageDeduplicationId: aws.String(msg.DeduplicationID()),
+		MessageGroupId:         aws.String(msg.MessageGroupID),
+	}
+	log.Println("[debug] publishing message:", in.String())
+	out, err := s.svc.PublishWithContext(ctx, in)
+	if err != nil {
+		return err
+	}
+	log.Println("[debug] published messageID:", *out.MessageId)
+	return nil
+}
+
+// arn:aws:sns:ap-northeast-1:123456789012:topic_name.fifo
+func parseTopicARN(s string) (arn.ARN, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return a, errors.Wrapf(err, "invalid topic ARN:%s", s)
+	}
+	if a.Service != "sns" || a.Region == "" || a.Resource == "" {
+		return a, errors.Errorf("invalid topic ARN:%s", s)
+	}
+	if !strings.HasSuffix(a.Resource, ".fifo") {
+		return a, errors.New("FIFO topic is required")
+	}
+	return a, nil
+}
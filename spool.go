@@ -0,0 +1,234 @@
+package sqsjfr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SpoolInterval defines an interval to retry sending spooled messages.
+var SpoolInterval = 5 * time.Second
+
+// Backoff parameters for retrying spooled messages.
+var (
+	SpoolInitialBackoff = 5 * time.Second
+	SpoolMaxBackoff     = 5 * time.Minute
+)
+
+const spoolSuffix = ".json"
+
+// spoolRecord represents a message which failed to send.
+type spoolRecord struct {
+	Message         *Message               `json:"message"`
+	Body            map[string]interface{} `json:"body,omitempty"`
+	QueueURL        string                 `json:"queue_url"`
+	MessageGroupID  string                 `json:"message_group_id"`
+	EventSource     string                 `json:"event_source,omitempty"`
+	EventDetailType string                 `json:"event_detail_type,omitempty"`
+	PartitionKey    string                 `json:"partition_key,omitempty"`
+	FunctionARN     string                 `json:"function_arn,omitempty"`
+	DelaySeconds    int64                  `json:"delay_seconds,omitempty"`
+	DeduplicationID string                 `json:"deduplication_id"`
+	SpooledAt       time.Time              `json:"spooled_at"`
+	Attempts        int                    `json:"attempts"`
+	NextAttemptAt   time.Time              `json:"next_attempt_at"`
+}
+
+func (r *spoolRecord) message() *Message {
+	msg := r.Message
+	msg.Body = r.Body
+	msg.QueueURL = r.QueueURL
+	msg.MessageGroupID = r.MessageGroupID
+	msg.EventSource = r.EventSource
+	msg.EventDetailType = r.EventDetailType
+	msg.PartitionKey = r.PartitionKey
+	msg.FunctionARN = r.FunctionARN
+	msg.DelaySeconds = r.DelaySeconds
+	msg.deduplicationID = r.DeduplicationID
+	return msg
+}
+
+// spool is a durable outbox for messages which failed to send.
+type spool struct {
+	dir    string
+	maxAge time.Duration
+	stats  *Stats
+}
+
+func newSpool(dir string, maxAge time.Duration, stats *Stats) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create spool directory %s", dir)
+	}
+	s := &spool{dir: dir, maxAge: maxAge, stats: stats}
+	names, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	atomic.StoreInt64(&stats.Spool.Depth, int64(len(names)))
+	if len(names) > 0 {
+		log.Printf("[info] %d messages found in spool %s", len(names), dir)
+	}
+	return s, nil
+}
+
+func (s *spool) list() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && strings.HasSuffix(f.Name(), spoolSuffix) {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s *spool) path(dedupID string) string {
+	return filepath.Join(s.dir, dedupID+spoolSuffix)
+}
+
+// Put writes a message to the spool.
+func (s *spool) Put(msg *Message) error {
+	now := time.Now()
+	r := &spoolRecord{
+		Message:         msg,
+		Body:            msg.Body,
+		QueueURL:        msg.QueueURL,
+		MessageGroupID:  msg.MessageGroupID,
+		EventSource:     msg.EventSource,
+		EventDetailType: msg.EventDetailType,
+		PartitionKey:    msg.PartitionKey,
+		FunctionARN:     msg.FunctionARN,
+		DelaySeconds:    msg.DelaySeconds,
+		DeduplicationID: msg.DeduplicationID(),
+		SpooledAt:       now,
+		NextAttemptAt:   now.Add(SpoolInitialBackoff),
+	}
+	path := s.path(r.DeduplicationID)
+	_, err := os.Stat(path)
+	exists := err == nil
+	if err := s.write(r); err != nil {
+		return err
+	}
+	if !exists {
+		atomic.AddInt64(&s.stats.Spool.Depth, 1)
+	}
+	return nil
+}
+
+func (s *spool) write(r *spoolRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(r.DeduplicationID), b)
+}
+
+func (s *spool) read(name string) (*spoolRecord, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keeps numbers in the body as is
+	var r spoolRecord
+	if err := dec.Decode(&r); err != nil {
+		return nil, err
+	}
+	if r.Message == nil {
+		return nil, errors.New("message is not found")
+	}
+	return &r, nil
+}
+
+func (s *spool) remove(name string) {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		log.Printf("[warn] failed to remove spooled message %s: %s", name, err)
+		return
+	}
+	atomic.AddInt64(&s.stats.Spool.Depth, -1)
+}
+
+// Retry tries to send spooled messages which are due.
+func (s *spool) Retry(now time.Time, send func(*Message) error) {
+	names, err := s.list()
+	if err != nil {
+		log.Println("[warn] failed to list spool:", err)
+		return
+	}
+	for _, name := range names {
+		r, err := s.read(name)
+		if err != nil {
+			log.Printf("[error] broken spooled message %s: %s", name, err)
+			s.remove(name)
+			atomic.AddInt64(&s.stats.Spool.Dropped, 1)
+			continue
+		}
+		if now.Before(r.NextAttemptAt) {
+			continue
+		}
+		msg := r.message()
+		err = send(msg)
+		if err == nil {
+			log.Printf("[info] [entry:%s] resent spooled message %s", msg.entry(), r.DeduplicationID)
+			s.remove(name)
+			atomic.AddInt64(&s.stats.Spool.Resent, 1)
+			continue
+		}
+		r.Attempts++
+		if s.maxAge > 0 && now.Sub(r.SpooledAt) > s.maxAge {
+			log.Printf("[error] [entry:%s] give up resending spooled message %s after %d attempts: %s", msg.entry(), r.DeduplicationID, r.Attempts, err)
+			s.remove(name)
+			atomic.AddInt64(&s.stats.Spool.Dropped, 1)
+			continue
+		}
+		r.NextAttemptAt = now.Add(backoff(SpoolInitialBackoff, SpoolMaxBackoff, r.Attempts))
+		log.Printf("[warn] [entry:%s] failed to resend spooled message %s (attempts %d, next %s): %s", msg.entry(), r.DeduplicationID, r.Attempts, r.NextAttemptAt.Format(time.RFC3339), err)
+		if err := s.write(r); err != nil {
+			log.Printf("[warn] failed to update spooled message %s: %s", name, err)
+		}
+	}
+}
+
+// backoff returns an exponential backoff duration for the attempts.
+func backoff(initial, max time.Duration, attempts int) time.Duration {
+	d := initial
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return d
+}
+
+func (app *App) runSpool() {
+	ticker := time.NewTicker(SpoolInterval)
+	defer ticker.Stop()
+	log.Printf("[info] starting up spool %s", app.spool.dir)
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case now := <-ticker.C:
+			app.spool.Retry(now, app.resend)
+		}
+	}
+}
+
+// resend resends a spooled message, and counts the result in stats.
+func (app *App) resend(msg *Message) error {
+	err := app.sendMessage(msg)
+	app.stats.countResend(msg.entry(), time.Unix(msg.InvokedAt, 0), err)
+	return err
+}
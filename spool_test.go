@@ -0,0 +1,129 @@
+package sqsjfr_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqsjfr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stats := &sqsjfr.Stats{}
+	s, err := sqsjfr.NewSpool(dir, time.Hour, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 10, 7, 11, 22, 33, 0, time.Local)
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: "date"}, "tests/message.template", now, time.Minute, map[string]string{"FOO": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.QueueURL = "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo"
+	msg.MessageGroupID = "test"
+	msg.DelaySeconds = 30
+	if err := s.Put(msg); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Spool.Depth != 1 {
+		t.Errorf("unexpected spool depth %d", stats.Spool.Depth)
+	}
+
+	var sent []*sqsjfr.Message
+	failure := func(m *sqsjfr.Message) error {
+		sent = append(sent, m)
+		return errors.New("failed")
+	}
+	success := func(m *sqsjfr.Message) error {
+		sent = append(sent, m)
+		return nil
+	}
+
+	s.Retry(time.Now(), failure) // not due yet
+	if len(sent) != 0 {
+		t.Errorf("unexpected sent before due %d", len(sent))
+	}
+	s.Retry(time.Now().Add(time.Minute), failure)
+	if len(sent) != 1 || stats.Spool.Depth != 1 {
+		t.Errorf("unexpected sent %d depth %d", len(sent), stats.Spool.Depth)
+	}
+	s.Retry(time.Now().Add(10*time.Minute), success)
+	if len(sent) != 2 || stats.Spool.Depth != 0 || stats.Spool.Resent != 1 {
+		t.Errorf("unexpected sent %d depth %d resent %d", len(sent), stats.Spool.Depth, stats.Spool.Resent)
+	}
+	resent := sent[1]
+	if resent.DeduplicationID() != msg.DeduplicationID() {
+		t.Errorf("deduplication id must be preserved %s != %s", resent.DeduplicationID(), msg.DeduplicationID())
+	}
+	if resent.String() != msg.String() {
+		t.Errorf("message body must be preserved %s != %s", resent.String(), msg.String())
+	}
+	if resent.QueueURL != msg.QueueURL || resent.MessageGroupID != msg.MessageGroupID {
+		t.Errorf("unexpected destination %s %s", resent.QueueURL, resent.MessageGroupID)
+	}
+	if resent.DelaySeconds != msg.DelaySeconds {
+		t.Errorf("delay must be preserved %d != %d", resent.DelaySeconds, msg.DelaySeconds)
+	}
+}
+
+func TestSpoolGiveUp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqsjfr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stats := &sqsjfr.Stats{}
+	s, err := sqsjfr.NewSpool(dir, time.Hour, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: "date"}, "", time.Now(), time.Minute, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(msg); err != nil {
+		t.Fatal(err)
+	}
+	s.Retry(time.Now().Add(2*time.Hour), func(*sqsjfr.Message) error {
+		return errors.New("failed")
+	})
+	if stats.Spool.Depth != 0 || stats.Spool.Dropped != 1 {
+		t.Errorf("unexpected depth %d dropped %d", stats.Spool.Depth, stats.Spool.Dropped)
+	}
+}
+
+func TestSpoolResendStats(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"AccessDenied", "Throttling"}
+
+	app := newRetryTestApp(ts.URL)
+	if err := app.SetSpool(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	msg := newTestMessage(t, ts.URL)
+	if err := app.Send(msg); err == nil {
+		t.Fatal("must be failed and spooled")
+	}
+	app.RetrySpool(time.Now().Add(time.Minute)) // Throttling
+	app.RetrySpool(time.Now().Add(10 * time.Minute))
+	if len(f.received) != 1 {
+		t.Fatalf("unexpected received %d", len(f.received))
+	}
+	stats := app.Stats()
+	if stats.Spool.Resent != 1 || stats.Invocations.Failed != 1 || stats.Invocations.Succeeded != 1 || stats.Invocations.Retried != 2 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+	if e := stats.EntryStats(msg.EntryKey); e.Failed != 1 || e.Succeeded != 1 || e.Retried != 2 {
+		t.Errorf("unexpected entry stats %#v", e)
+	}
+}
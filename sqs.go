@@ -0,0 +1,184 @@
+package sqsjfr
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/pkg/errors"
+)
+
+// SQSBatchWindow defines a duration to wait for messages due in the same tick to send them at once.
+var SQSBatchWindow = 100 * time.Millisecond
+
+// Limits of a SendMessageBatch request.
+const (
+	sqsMaxBatchEntries = 10
+	sqsMaxBatchBytes   = 256 * 1024
+)
+
+// sqsEntry represents a message waiting to be sent.
+type sqsEntry struct {
+	ctx  context.Context
+	msg  *Message
+	body string
+	done chan error
+}
+
+// sqsBatch represents messages to the same queue due in the same tick.
+type sqsBatch struct {
+	queueURL string
+	entries  []*sqsEntry
+	bytes    int
+}
+
+// sqsBatchKey identifies a batch by the queue and the tick.
+type sqsBatchKey struct {
+	queueURL  string
+	invokedAt int64
+}
+
+// sqsSender sends messages to SQS queues.
+// Messages to the same queue due in the same tick are coalesced into SendMessageBatch requests.
+type sqsSender struct {
+	svc *sqs.SQS
+
+	mu      sync.Mutex
+	batches map[sqsBatchKey]*sqsBatch
+}
+
+func newSQSSender(sess *session.Session) *sqsSender {
+	return &sqsSender{
+		svc:     sqs.New(sess, aws.NewConfig().WithMaxRetries(0)), // retried by sendWithRetry
+		batches: make(map[sqsBatchKey]*sqsBatch),
+	}
+}
+
+// Send waits for the result of the batch even if the context is done, because the request may succeed after that.
+// The request of the batch is canceled by the earliest deadline of the entries.
+func (s *sqsSender) Send(ctx context.Context, msg *Message) error {
+	e := &sqsEntry{ctx: ctx, msg: msg, body: msg.String(), done: make(chan error, 1)}
+	s.add(e)
+	return <-e.done
+}
+
+// add adds the entry to the batch of the queue and the tick. The batch is flushed when it is full or after SQSBatchWindow.
+func (s *sqsSender) add(e *sqsEntry) {
+	key := sqsBatchKey{queueURL: e.msg.QueueURL, invokedAt: e.msg.InvokedAt}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[key]
+	if ok && b.bytes+len(e.body) > sqsMaxBatchBytes {
+		// sends the current batch not to exceed the payload limit
+		delete(s.batches, key)
+		go s.send(b)
+		ok = false
+	}
+	if !ok {
+		b = &sqsBatch{queueURL: key.queueURL}
+		s.batches[key] = b
+		time.AfterFunc(SQSBatchWindow, func() { s.flush(key, b) })
+	}
+	b.entries = append(b.entries, e)
+	b.bytes += len(e.body)
+	if len(b.entries) >= sqsMaxBatchEntries {
+		delete(s.batches, key)
+		go s.send(b)
+	}
+}
+
+func (s *sqsSender) flush(key sqsBatchKey, b *sqsBatch) {
+	s.mu.Lock()
+	if s.batches[key] != b {
+		s.mu.Unlock()
+		return // already flushed
+	}
+	delete(s.batches, key)
+	s.mu.Unlock()
+	s.send(b)
+}
+
+// send sends the batch by a SendMessageBatch request, and notifies the result for each entry.
+func (s *sqsSender) send(b *sqsBatch) {
+	// messages in a batch are delivered in order of the position in crontab
+	sort.SliceStable(b.entries, func(i, k int) bool {
+		return b.entries[i].msg.EntryID < b.entries[k].msg.EntryID
+	})
+	// standard queues reject a deduplication ID and a message group ID
+	fifo := isFIFOQueue(b.queueURL)
+	in := &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(b.queueURL),
+	}
+	for i, e := range b.entries {
+		entry := &sqs.SendMessageBatchRequestEntry{
+			Id:          aws.String(strconv.Itoa(i)),
+			MessageBody: aws.String(e.body),
+		}
+		if e.msg.DelaySeconds > 0 {
+			entry.DelaySeconds = aws.Int64(e.msg.DelaySeconds)
+		}
+		if fifo {
+			entry.MessageDeduplicationId = aws.String(e.msg.DeduplicationID())
+			entry.MessageGroupId = aws.String(e.msg.MessageGroupID)
+		}
+		in.Entries = append(in.Entries, entry)
+	}
+	ctx, cancel := b.context()
+	defer cancel()
+	log.Printf("[debug] sending %d messages to %s", len(b.entries), b.queueURL)
+	out, err := s.svc.SendMessageBatchWithContext(ctx, in)
+	if err != nil {
+		for _, e := range b.entries {
+			e.done <- err
+		}
+		return
+	}
+	if len(out.Failed) > 0 {
+		log.Printf("[warn] %d of %d messages failed to send to %s", len(out.Failed), len(b.entries), b.queueURL)
+	}
+	results := make(map[string]error, len(b.entries))
+	for _, r := range out.Successful {
+		results[aws.StringValue(r.Id)] = nil
+		log.Printf("[debug] sent messageID: %s", aws.StringValue(r.MessageId))
+	}
+	for _, r := range out.Failed {
+		results[aws.StringValue(r.Id)] = awserr.New(aws.StringValue(r.Code), aws.StringValue(r.Message), nil)
+	}
+	for i, e := range b.entries {
+		err, ok := results[strconv.Itoa(i)]
+		if !ok {
+			err = errors.New("result of the message is not found")
+		}
+		e.done <- err
+	}
+}
+
+// context returns a context of the request, which has the earliest deadline of the entries.
+func (b *sqsBatch) context() (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(SQSTimeout)
+	for _, e := range b.entries {
+		if d, ok := e.ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+	}
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// isFIFOQueue reports whether the queue name (or URL) is of a FIFO queue.
+func isFIFOQueue(s string) bool {
+	return strings.HasSuffix(s, ".fifo")
+}
+
+// sqsQueueURL returns a queue URL of sqs://sqs.{region}.amazonaws.com/{account}/{queue}.
+func sqsQueueURL(u *url.URL) string {
+	return "https://" + u.Host + u.Path
+}
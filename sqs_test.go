@@ -0,0 +1,231 @@
+package sqsjfr_test
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+// fakeSQS is a fake SQS endpoint which accepts SendMessageBatch actions, and SNS Publish actions.
+type fakeSQS struct {
+	mu           sync.Mutex
+	errors       []string // error codes to respond in order
+	entryErrors  []string // error codes of batch entries in order, "" for success
+	received     []map[string]string
+	batchLengths []int
+}
+
+func newFakeSQS() (*fakeSQS, *httptest.Server) {
+	f := &fakeSQS{}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errors) > 0 {
+		code := f.errors[0]
+		f.errors = f.errors[1:]
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>fake error</Message></Error><RequestId>req</RequestId></ErrorResponse>`, code)
+		return
+	}
+	switch r.Form.Get("Action") {
+	case "SendMessageBatch":
+		var b strings.Builder
+		n := 0
+		for i := 1; ; i++ {
+			prefix := fmt.Sprintf("SendMessageBatchRequestEntry.%d.", i)
+			id := r.Form.Get(prefix + "Id")
+			if id == "" {
+				break
+			}
+			n++
+			var code string
+			if len(f.entryErrors) > 0 {
+				code, f.entryErrors = f.entryErrors[0], f.entryErrors[1:]
+			}
+			if code != "" {
+				fmt.Fprintf(&b, `<BatchResultErrorEntry><Id>%s</Id><Code>%s</Code><Message>fake error</Message><SenderFault>false</SenderFault></BatchResultErrorEntry>`, id, code)
+				continue
+			}
+			m := make(map[string]string)
+			for k := range r.Form {
+				if strings.HasPrefix(k, prefix) {
+					m[strings.TrimPrefix(k, prefix)] = r.Form.Get(k)
+				}
+			}
+			f.received = append(f.received, m)
+			fmt.Fprintf(&b, `<SendMessageBatchResultEntry><Id>%s</Id><MessageId>msg-%d</MessageId><MD5OfMessageBody>%x</MD5OfMessageBody></SendMessageBatchResultEntry>`,
+				id, len(f.received), md5.Sum([]byte(m["MessageBody"])))
+		}
+		f.batchLengths = append(f.batchLengths, n)
+		fmt.Fprintf(w, `<SendMessageBatchResponse><SendMessageBatchResult>%s</SendMessageBatchResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></SendMessageBatchResponse>`, b.String())
+	case "Publish":
+		m := make(map[string]string)
+		for k := range r.Form {
+			m[k] = r.Form.Get(k)
+		}
+		f.received = append(f.received, m)
+		fmt.Fprintf(w, `<PublishResponse><PublishResult><MessageId>msg-%d</MessageId></PublishResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></PublishResponse>`,
+			len(f.received))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>InvalidAction</Code><Message>not supported</Message></Error><RequestId>req</RequestId></ErrorResponse>`)
+	}
+}
+
+func TestSendMessageBatch(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	app := newRetryTestApp(ts.URL)
+	invokedAt := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: fmt.Sprintf("echo %d", i)}, "", invokedAt, time.Minute, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.QueueURL = ts.URL + "/123456789012/test.fifo"
+		msg.MessageGroupID = sqsjfr.DefaultMessageGroupID
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.Send(msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(f.received) != 25 {
+		t.Errorf("unexpected received len %d", len(f.received))
+	}
+	total := 0
+	for _, n := range f.batchLengths {
+		if n > 10 {
+			t.Errorf("too large batch %v", f.batchLengths)
+		}
+		total += n
+	}
+	if total != 25 {
+		t.Errorf("unexpected batches %v", f.batchLengths)
+	}
+}
+
+func TestSendMessageBatchOrder(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	app := newRetryTestApp(ts.URL)
+	app.Option().CrontabURL = "tests/crontab.names"
+	app.Option().QueueURL = ts.URL + "/123456789012/test.fifo"
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	invokedAt := time.Now()
+	var msgs []*sqsjfr.Message
+	for _, entry := range app.Entries() {
+		msg, err := app.NewMessage(entry.Job.(*sqsjfr.Job), invokedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	// sends in reverse order
+	var wg sync.WaitGroup
+	for i := len(msgs) - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(msg *sqsjfr.Message) {
+			defer wg.Done()
+			app.Send(msg)
+		}(msgs[i])
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	if len(f.batchLengths) != 1 || len(f.received) != len(msgs) {
+		t.Fatalf("unexpected batches %v", f.batchLengths)
+	}
+	for i, msg := range msgs {
+		if f.received[i]["MessageBody"] != msg.String() {
+			t.Errorf("message %d is not in order of crontab: %s", i, f.received[i]["MessageBody"])
+		}
+	}
+}
+
+func TestSendMessageBatchPartialFailure(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.entryErrors = []string{"", "ServiceUnavailable", ""}
+
+	app := newRetryTestApp(ts.URL)
+	invokedAt := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		msg, err := sqsjfr.NewMessage(&sqsjfr.Job{Command: fmt.Sprintf("echo %d", i)}, "", invokedAt, time.Minute, map[string]string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.QueueURL = ts.URL + "/123456789012/test"
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := app.Send(msg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(f.received) != 3 {
+		t.Errorf("unexpected received len %d", len(f.received))
+	}
+	// only the failed message is retried
+	if len(f.batchLengths) != 2 || f.batchLengths[0] != 3 || f.batchLengths[1] != 1 {
+		t.Errorf("unexpected batches %v", f.batchLengths)
+	}
+	if _, ok := f.received[0]["MessageGroupId"]; ok {
+		t.Errorf("standard queue must not have MessageGroupId %v", f.received[0])
+	}
+	stats := app.Stats()
+	if stats.Invocations.Succeeded != 3 || stats.Invocations.Failed != 0 || stats.Invocations.Retried != 1 {
+		t.Errorf("unexpected stats %#v", stats.Invocations)
+	}
+}
+
+func TestSendDelaySeconds(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+
+	for _, queue := range []string{"standard", "test.fifo"} {
+		app := newRetryTestApp(ts.URL)
+		app.Option().QueueURL = ts.URL + "/123456789012/" + queue
+		j := &sqsjfr.Job{Command: "echo " + queue, Location: time.UTC, Delay: 30 * time.Second}
+		msg, err := app.NewMessage(j, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Send(msg); err != nil {
+			t.Error(err)
+		}
+	}
+	if len(f.received) != 2 {
+		t.Fatalf("unexpected received len %d", len(f.received))
+	}
+	if d := f.received[0]["DelaySeconds"]; d != "30" {
+		t.Errorf("standard queue must have DelaySeconds 30, got %q", d)
+	}
+	if d, ok := f.received[1]["DelaySeconds"]; ok {
+		t.Errorf("FIFO queue must not have DelaySeconds, got %q", d)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"regexp"
 	"strings"
@@ -14,9 +15,7 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/hashicorp/go-envparse"
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
@@ -26,34 +25,37 @@ var (
 	reSpace        = regexp.MustCompile("[ \t\n\v\f\r\u0085\u00A0]+")
 	reTrimPrefix   = regexp.MustCompile("^[ \t\n\v\f\r\u0085\u00A0]+")
 	reLooksLikeEnv = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*=")
+	reNameComment  = regexp.MustCompile(`^#\s*name:\s*(\S*)\s*$`)
+	reEntryName    = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
 )
 
-// SQSTimeout defines a timeout to send message.
-var SQSTimeout = 10 * time.Second
-
-type errorReload struct{}
+// DefaultMessageGroupID defines a default MessageGroupId of messages.
+const DefaultMessageGroupID = "sqsjfr"
 
-func (e errorReload) Error() string {
-	return "reloading required"
-}
-
-var errReload = errorReload{}
+// SQSTimeout defines a timeout to send message to the destination.
+var SQSTimeout = 10 * time.Second
 
 // App represents a sqsjfr application instance.
 type App struct {
 	option *Option
 	cron   *cron.Cron
 	envs   Environments
-	sqs    *sqs.SQS
+	sender Sender
 	sess   *session.Session
 
 	ctx    context.Context
-	reload context.Context
-	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	mu     sync.RWMutex
 	digest []byte
 
 	stats *Stats
+	state *stateStore
+	spool *spool
+	dedup deduplicator
+	lease lease
+
+	pausedMu sync.RWMutex
+	paused   map[string]bool
 }
 
 // New creates an App instance.
@@ -64,33 +66,80 @@ func New(ctx context.Context, opt *Option) (*App, error) {
 	}
 	app := &App{
 		option: opt,
-		sqs:    sqs.New(sess),
 		sess:   sess,
 		ctx:    ctx,
 		stats:  &Stats{},
+		paused: make(map[string]bool),
+	}
+	if opt.StateURL != "" {
+		app.state = newStateStore(opt.StateURL, sess)
+	}
+	if err := opt.Validate(); err != nil {
+		return app, err
+	}
+	if opt.Sender != nil {
+		app.sender = opt.Sender
+	} else if app.sender, err = newSender(opt, sess, app.stats); err != nil {
+		return app, err
+	}
+	if opt.DedupURL != "" {
+		if app.dedup, err = newDeduplicator(opt.DedupURL, opt.DedupTTL, sess); err != nil {
+			return app, err
+		}
+	}
+	if opt.LeaseURL != "" {
+		if app.lease, err = newLease(opt.LeaseURL, sess); err != nil {
+			return app, err
+		}
+		app.stats.Leader = &LeaderStats{holder: newHolderID()}
+	}
+	if opt.SpoolDir != "" {
+		if app.spool, err = newSpool(opt.SpoolDir, opt.SpoolMaxAge, app.stats); err != nil {
+			return app, err
+		}
 	}
-	return app, opt.Validate()
+	return app, nil
 }
 
 // Run runs sqsjfr instance.
 func (app *App) Run() error {
+	defer app.closeSender()
 	go func() {
 		if err := app.runStatsServer(); err != nil {
 			panic(err)
 		}
 	}()
-	for {
-		app.reload, app.cancel = context.WithCancel(context.Background())
-		if err := app.run(); err == nil {
-			// normarly shutdown
-			log.Println("[info] goodby")
-			return nil
-		} else if _, ok := err.(errorReload); ok {
-			log.Println("[info] reloading")
-			continue
-		} else {
+	if app.state != nil {
+		if err := app.state.Load(); err != nil {
 			return err
 		}
+		app.applyPausedState()
+		go app.flushState()
+		defer func() {
+			if err := app.saveState(); err != nil {
+				log.Println("[warn]", err)
+			}
+		}()
+	}
+	if app.spool != nil && !app.option.DryRun {
+		go app.runSpool()
+	}
+	if err := app.run(); err != nil {
+		return err
+	}
+	// normarly shutdown
+	log.Println("[info] goodby")
+	return nil
+}
+
+// closeSender closes the sender which holds resources (e.g. a file of file:// destination).
+func (app *App) closeSender() {
+	c, ok := app.sender.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := c.Close(); err != nil {
+		log.Println("[warn] failed to close the destination:", err)
 	}
 }
 
@@ -109,26 +158,40 @@ func (app *App) watch() {
 			return
 		case <-ticker.C:
 		}
-		newDigest, err := func() ([]byte, error) {
-			f, err := app.ReadCrontabFile()
-			if err != nil {
-				return nil, err
-			}
-			defer f.Close()
-			_, _, digest, err := readCrontab(f, newDummyJob)
-			return digest, err
-		}()
-		if err != nil {
+		if err := app.checkCrontab(); err != nil {
 			log.Println("[warn]", err)
-			continue
 		}
-		if !bytes.Equal(app.digest, newDigest) {
-			log.Printf("[info] crontab is modified %x -> %x", app.digest, newDigest)
-			app.cancel()
-			return
+	}
+}
+
+// checkCrontab reads crontab and reloads it when modified.
+func (app *App) checkCrontab() error {
+	b, err := func() ([]byte, error) {
+		f, err := app.ReadCrontabFile()
+		if err != nil {
+			return nil, err
 		}
+		defer f.Close()
+		return ioutil.ReadAll(f)
+	}()
+	if err != nil {
+		return err
+	}
+	_, _, newDigest, err := readCrontab(bytes.NewReader(b), app.option, newDummyJob)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(app.digest, newDigest) {
 		log.Printf("[debug] digest unchanged %x", app.digest)
+		return nil
+	}
+	log.Printf("[info] crontab is modified %x -> %x", app.digest, newDigest)
+	c, envs, _, err := readCrontab(bytes.NewReader(b), app.option, app.newJob)
+	if err != nil {
+		return err
 	}
+	app.reload(c, envs, newDigest)
+	return nil
 }
 
 func (app *App) run() error {
@@ -143,53 +206,82 @@ func (app *App) run() error {
 	go app.watch()
 
 	log.Println("[info] running daemon")
-	app.cron.Start()
-	var err error
-	select {
-	case <-app.ctx.Done():
-	case <-app.reload.Done():
-		err = errReload
-	}
-	app.cron.Stop()
+	if app.lease != nil {
+		// the scheduler runs only while this process is the leader
+		app.runLeaderElection()
+	} else {
+		if app.state != nil {
+			app.catchUp(time.Now())
+		}
+		app.cron.Start()
+		<-app.ctx.Done()
+		app.cron.Stop()
+	}
 	log.Println("[info] shutting down")
 	app.wg.Wait() // wait all invoke functions
-	return err
+	return nil
 }
 
-func readCrontab(r io.Reader, fn func(string) cron.Job) (*cron.Cron, Environments, []byte, error) {
-	c := cron.New()
+func readCrontab(r io.Reader, opt *Option, fn func(string) cron.Job) (*cron.Cron, Environments, []byte, error) {
+	loc, err := opt.location()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c := cron.New(cron.WithLocation(loc), cron.WithParser(opt.parser()))
 	h := sha256.New()
 	r = io.TeeReader(r, h)
 	scanner := bufio.NewScanner(r)
 	lines := 0
 	envsBuf := bytes.NewBuffer([]byte{})
+	reg := newEntryRegistry()
 	for scanner.Scan() {
 		lines++
 		line := scanner.Text()
 		line = reTrimPrefix.ReplaceAllString(line, "")
+		reg.comment(line)
 		if line == "" || strings.HasPrefix(line, "#") { // skip
 			envsBuf.WriteString("\n") // required for valid "error on line x"
 			continue
 		}
+		if strings.HasPrefix(line, "CRON_TZ=") {
+			envsBuf.WriteString("\n")
+			loc, err = parseCronTZ(line)
+			if err != nil {
+				return nil, nil, nil, errors.Wrapf(err, "line %d, invalid CRON_TZ > %s", lines, line)
+			}
+			continue
+		}
 		if reLooksLikeEnv.MatchString(line) {
 			envsBuf.WriteString(line + "\n")
 			continue
 		}
 		envsBuf.WriteString("\n")
-		f := reSpace.Split(line, 6)
-		if len(f) < 6 {
-			return nil, nil, nil, fmt.Errorf("line %d, too few feilds > %s", lines, line)
-		}
-		spec := strings.Join(f[0:5], " ")
-		command := f[5]
-		job := fn(command)
-		id, err := c.AddJob(spec, job)
+		e, err := parseEntry(line, loc, opt)
 		if err != nil {
-			return nil, nil, nil, errors.Wrapf(err, "line %d, failed to add > %s", lines, line)
+			return nil, nil, nil, fmt.Errorf("line %d, %s", lines, err)
+		}
+		name := reg.takeName(e.options.Name)
+		if name != "" && !reEntryName.MatchString(name) {
+			return nil, nil, nil, fmt.Errorf("line %d, invalid name %s", lines, name)
+		}
+		if err := reg.register(lines, name, e); err != nil {
+			return nil, nil, nil, fmt.Errorf("line %d, %s", lines, err)
 		}
+		job := fn(e.command)
+		id := c.Schedule(e.schedule, job)
 		if j, ok := job.(*Job); ok {
 			j.ID = id
-			log.Printf("[info] [entry:%d] registered > %s", id, line)
+			j.index = int64(len(c.Entries()))
+			j.setEntry(e, name)
+			if opt.ScopedEnv {
+				// captures environment variables defined at the line
+				envs, err := envparse.Parse(bytes.NewReader(envsBuf.Bytes()))
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				j.Env = Environments(envs)
+			}
+			log.Printf("[debug] [entry:%s] parsed (%s) > %s", j, loc, line)
 		}
 	}
 
@@ -200,6 +292,139 @@ func readCrontab(r io.Reader, fn func(string) cron.Job) (*cron.Cron, Environment
 	return c, Environments(envs), h.Sum(nil), nil
 }
 
+// crontabEntry represents a parsed entry line of crontab.
+type crontabEntry struct {
+	spec     string
+	command  string
+	schedule cron.Schedule
+	options  *entryOptions
+	location *time.Location
+	stableID string
+}
+
+// parseEntry parses an entry line of crontab in the location.
+func parseEntry(line string, loc *time.Location, opt *Option) (*crontabEntry, error) {
+	o, entry, err := splitEntryOptions(line)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid options > %s", line)
+	}
+	eo, err := parseEntryOptions(o, opt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid options > %s", line)
+	}
+	n := specFields(entry, opt.Seconds)
+	f := reSpace.Split(entry, n+1)
+	if len(f) < n+1 {
+		return nil, fmt.Errorf("too few feilds > %s", line)
+	}
+	spec := strings.Join(f[0:n], " ")
+	schedule, err := opt.parser().Parse("CRON_TZ=" + loc.String() + " " + spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse > %s", line)
+	}
+	if err := validateSchedule(schedule, opt.precision()); err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule > %s", line)
+	}
+	return &crontabEntry{
+		spec:     spec,
+		command:  f[n],
+		schedule: schedule,
+		options:  eo,
+		location: loc,
+		stableID: stableID(loc, spec, f[n]),
+	}, nil
+}
+
+// entryRegistry names entries of crontab, and detects duplicated entries.
+type entryRegistry struct {
+	name    string         // name for the next entry by a name comment
+	names   map[string]int // lines of names
+	unnamed map[string]int // lines of stable IDs of unnamed entries
+}
+
+func newEntryRegistry() *entryRegistry {
+	return &entryRegistry{
+		names:   make(map[string]int),
+		unnamed: make(map[string]int),
+	}
+}
+
+// comment reads a line which may be a name comment "# name: {name}" for the next entry.
+func (r *entryRegistry) comment(line string) {
+	if m := reNameComment.FindStringSubmatch(line); m != nil {
+		r.name = m[1]
+	}
+}
+
+// takeName returns the name of the entry by the option or the preceding name comment.
+// The name comment is consumed by the entry.
+func (r *entryRegistry) takeName(option string) string {
+	name := r.name
+	r.name = ""
+	if option != "" {
+		return option
+	}
+	return name
+}
+
+// register registers the entry at the line.
+// An entry is identified by the name, or the stable ID when not named.
+func (r *entryRegistry) register(line int, name string, e *crontabEntry) error {
+	if name != "" {
+		if l, ok := r.names[name]; ok {
+			return fmt.Errorf("name %s is already used on line %d", name, l)
+		}
+		r.names[name] = line
+		return nil
+	}
+	if l, ok := r.unnamed[e.stableID]; ok {
+		return fmt.Errorf("the same entry as line %d (name this entry)", l)
+	}
+	r.unnamed[e.stableID] = line
+	return nil
+}
+
+// stableID returns an ID of the entry which is not changed when other entries are modified.
+func stableID(loc *time.Location, spec, command string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s", loc, spec, command)
+	return fmt.Sprintf("%x", h.Sum(nil))[0:16]
+}
+
+// specFields returns a number of fields of the schedule spec in the line.
+func specFields(line string, seconds bool) int {
+	switch {
+	case strings.HasPrefix(line, "@every"):
+		return 2 // @every <duration>
+	case strings.HasPrefix(line, "@"):
+		return 1 // @hourly, @daily, ...
+	case seconds:
+		return 6
+	default:
+		return 5
+	}
+}
+
+// validateSchedule validates the schedule can be invoked in the precision of InvokedAt.
+// Otherwise, messages invoked in the same precision would have the same deduplication ID.
+func validateSchedule(s cron.Schedule, precision time.Duration) error {
+	if d, ok := s.(cron.ConstantDelaySchedule); ok {
+		if d.Delay%precision != 0 {
+			return fmt.Errorf("@every interval %s must be a multiple of %s", d.Delay, precision)
+		}
+	}
+	return nil
+}
+
+// parseCronTZ parses a CRON_TZ=... line and returns the location.
+func parseCronTZ(line string) (*time.Location, error) {
+	env, err := envparse.Parse(strings.NewReader(line))
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(env["CRON_TZ"])
+}
+
 func (app *App) load() error {
 	log.Println("[info] loading crontab", app.option.CrontabURL)
 	f, err := app.ReadCrontabFile()
@@ -208,12 +433,17 @@ func (app *App) load() error {
 	}
 	defer f.Close()
 
-	app.cron, app.envs, app.digest, err = readCrontab(f, app.newJob)
+	app.cron, app.envs, app.digest, err = readCrontab(f, app.option, app.newJob)
 	if err != nil {
 		return errors.Wrapf(err, "failed to read crontab %s", app.option.CrontabURL)
 	}
 
 	log.Printf("[debug] crontab digest %x", app.digest)
+	for _, entry := range app.cron.Entries() {
+		if j, ok := entry.Job.(*Job); ok {
+			log.Printf("[info] [entry:%s] registered (%s) > %s %s", j, j.Location, j.Spec, j.Command)
+		}
+	}
 	log.Printf("[info] %d entries registered", len(app.cron.Entries()))
 	atomic.StoreInt64(&app.stats.Entries.Registered, int64(len(app.cron.Entries())))
 
@@ -225,67 +455,204 @@ func (app *App) load() error {
 }
 
 func (app *App) send(msg *Message) error {
-	ctx, cancel := context.WithTimeout(context.Background(), SQSTimeout)
-	defer cancel()
-	in := &sqs.SendMessageInput{
-		QueueUrl:               aws.String(app.option.QueueURL),
-		MessageBody:            aws.String(msg.String()),
-		MessageDeduplicationId: aws.String(msg.DeduplicationID()),
-		MessageGroupId:         aws.String("sqsjfr"),
-	}
-	log.Println("[debug] sending message:", in.String())
-	out, err := app.sqs.SendMessageWithContext(ctx, in)
+	if app.dedup != nil && !app.claim(msg) {
+		return nil
+	}
+	err := app.sendWithRetry(msg)
+	app.stats.countInvocation(msg.entry(), time.Unix(msg.InvokedAt, 0), err)
 	if err != nil {
-		atomic.AddInt64(&app.stats.Invocations.Failed, 1)
+		spooled := false
+		if app.spool != nil {
+			if serr := app.spool.Put(msg); serr != nil {
+				log.Printf("[error] [entry:%s] failed to spool message: %s", msg.entry(), serr)
+			} else {
+				log.Printf("[info] [entry:%s] spooled message %s to retry", msg.entry(), msg.DeduplicationID())
+				spooled = true
+			}
+		}
+		if app.dedup != nil && !spooled {
+			// the spooled message keeps the claim to be resent by this process
+			app.release(msg)
+		}
 		return err
 	}
-	atomic.AddInt64(&app.stats.Invocations.Succeeded, 1)
-	log.Println("[debug] sent messageID:", *out.MessageId)
 	return nil
 }
 
-func (app *App) newMessage(command string) (*Message, error) {
-	return newMessage(command, app.option.MessageTemplate, time.Now(), app.envs)
+func (app *App) sendMessage(msg *Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), app.option.sendTimeout())
+	defer cancel()
+	start := time.Now()
+	err := app.sender.Send(ctx, msg)
+	app.stats.sendLatency.Observe(time.Since(start))
+	return err
+}
+
+func (app *App) newMessage(j *Job, t time.Time) (*Message, error) {
+	now := t.In(j.Location)
+	app.mu.RLock()
+	envs := app.envs
+	app.mu.RUnlock()
+	if j.Env != nil {
+		envs = j.Env
+	}
+	tmpl := app.option.MessageTemplate
+	if j.MessageTemplate != "" {
+		tmpl = j.MessageTemplate
+	}
+	msg, err := newMessage(j, tmpl, now, app.option.precision(), envs)
+	if err != nil {
+		return nil, err
+	}
+	msg.QueueURL = app.option.queueURL()
+	if j.QueueURL != "" {
+		msg.QueueURL = j.QueueURL
+	}
+	msg.MessageGroupID = DefaultMessageGroupID
+	if j.MessageGroupID != "" {
+		msg.MessageGroupID = j.MessageGroupID
+	}
+	msg.FunctionARN = j.FunctionARN
+	if j.Delay > 0 && !j.manual && app.delaysBySQS(msg) {
+		msg.DelaySeconds = int64(j.Delay / time.Second)
+	}
+	if err := setDestinationFields(app.option, j, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// setDestinationFields sets fields of the message specific to the destination, rendered by templates.
+func setDestinationFields(opt *Option, j *Job, msg *Message) error {
+	switch opt.destinationScheme() {
+	case "eventbridge":
+		return setEventFields(opt, j, msg)
+	case "kinesis":
+		return setPartitionKey(opt, j, msg)
+	}
+	return nil
+}
+
+// delaysBySQS reports whether the queue of the message delays it by DelaySeconds.
+// FIFO queues support only the queue level delay.
+func (app *App) delaysBySQS(msg *Message) bool {
+	return app.option.destinationScheme() == "sqs" && !isFIFOQueue(msg.QueueURL)
 }
 
 func (app *App) newJob(command string) cron.Job {
 	log.Printf("[debug] new job command:%s", command)
 	return &Job{
 		Command:   command,
+		ctx:       app.ctx,
 		wg:        &app.wg,
 		generator: app.newMessage,
 		sender:    app.send,
+		recorder:  app.recordFired,
+		isPaused:  app.isPaused,
+	}
+}
+
+func (app *App) recordFired(j *Job, t time.Time) {
+	if app.state != nil {
+		app.state.SetLastFired(j.String(), t)
 	}
 }
 
 // Job represents a cron job.
 type Job struct {
-	ID      cron.EntryID
-	Command string
-
+	ID       cron.EntryID
+	StableID string
+	Name     string
+	Spec     string
+	Command  string
+	Location *time.Location
+	Env      Environments
+
+	QueueURL        string
+	MessageGroupID  string
+	Delay           time.Duration
+	MessageTemplate string
+	EventSource     string
+	EventDetailType string
+	PartitionKey    string
+	FunctionARN     string
+
+	ctx       context.Context
 	wg        *sync.WaitGroup
-	generator func(string) (*Message, error)
+	generator func(*Job, time.Time) (*Message, error)
 	sender    func(*Message) error
+	recorder  func(*Job, time.Time)
+	isPaused  func(*Job) bool
+	index     int64 // position in crontab, renumbered by reload
+	manual    bool  // invoked manually by admin API
 }
 
-func (j *Job) delay() time.Duration {
-	return 100 * time.Millisecond * time.Duration(j.ID)
+// setEntry sets fields of the job by the parsed entry.
+func (j *Job) setEntry(e *crontabEntry, name string) {
+	eo := e.options
+	j.StableID = e.stableID
+	j.Name = name
+	j.Spec = e.spec
+	j.Command = e.command
+	j.Location = e.location
+	j.QueueURL = eo.QueueURL
+	j.MessageGroupID = eo.MessageGroupID
+	j.Delay = eo.Delay
+	j.MessageTemplate = eo.MessageTemplate
+	j.EventSource = eo.EventSource
+	j.EventDetailType = eo.EventDetailType
+	j.PartitionKey = eo.PartitionKey
+	j.FunctionARN = eo.FunctionARN
+}
+
+// String returns the name of the job, or the stable ID when the job is not named.
+func (j *Job) String() string {
+	if j.Name != "" {
+		return j.Name
+	}
+	return j.StableID
 }
 
 // Run runs a Job.
 func (j *Job) Run() {
+	j.invoke(time.Now())
+}
+
+// invoke invokes a Job as invoked at t.
+func (j *Job) invoke(t time.Time) {
 	j.wg.Add(1)
 	defer j.wg.Done()
 
-	msg, err := j.generator(j.Command)
+	if j.isPaused != nil && j.isPaused(j) {
+		log.Printf("[info] [entry:%s] skipped by paused", j)
+		// skipped invocations are recorded as fired, not to be caught up after resumed
+		if j.recorder != nil {
+			j.recorder(j, t)
+		}
+		return
+	}
+	msg, err := j.generator(j, t)
 	if err != nil {
-		log.Printf("[warn] [entry:%d] %s", j.ID, err)
+		log.Printf("[warn] [entry:%s] %s", j, err)
 		return
 	}
-	time.Sleep(j.delay())
-	log.Printf("[info] [entry:%d] invoke job %s", j.ID, msg.String())
+	if j.Delay > 0 && msg.DelaySeconds == 0 {
+		// the destination does not support a per-message delay. waits in the process
+		log.Printf("[debug] [entry:%s] delay %s", j, j.Delay)
+		select {
+		case <-j.ctx.Done():
+			log.Printf("[warn] [entry:%s] shutting down, discarded the delayed message %s", j, msg.String())
+			return
+		case <-time.After(j.Delay):
+		}
+	}
+	log.Printf("[info] [entry:%s] invoke job %s", j, msg.String())
 	if err := j.sender(msg); err != nil {
-		log.Printf("[error] [entry:%d] failed to send message: %s", j.ID, err)
+		log.Printf("[error] [entry:%s] failed to send message: %s", j, err)
+	}
+	// recorded after sending, so a delayed message discarded by shutting down is caught up after restarted
+	if j.recorder != nil {
+		j.recorder(j, t)
 	}
 }
 
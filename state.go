@@ -0,0 +1,362 @@
+package sqsjfr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// Catch-up policies for missed invocations.
+const (
+	CatchUpNone = "none"
+	CatchUpOnce = "once"
+	CatchUpAll  = "all"
+)
+
+// StateFlushInterval defines an interval to save the state.
+var StateFlushInterval = 10 * time.Second
+
+// State represents a persisted state of sqsjfr.
+type State struct {
+	// LastFired is a map of entry name (or stable ID) to the last invoked UNIX time.
+	LastFired map[string]int64 `json:"last_fired"`
+	// Paused is a set of paused entry names (or stable IDs).
+	Paused map[string]bool `json:"paused,omitempty"`
+}
+
+type stateStore struct {
+	url  string
+	sess *session.Session
+
+	mu      sync.Mutex
+	state   State
+	dirty   bool
+	pending map[string]bool // paused entries modified after the last save
+}
+
+func newStateStore(u string, sess *session.Session) *stateStore {
+	return &stateStore{
+		url:   u,
+		sess:  sess,
+		state: State{LastFired: make(map[string]int64)},
+	}
+}
+
+// Load loads the state from the store. A state which does not exist yet is not an error.
+func (s *stateStore) Load() error {
+	state, found, err := s.read()
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Printf("[info] state %s is not found. starting with an empty state", s.url)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	return nil
+}
+
+// read reads the state from the store, and reports whether the state exists.
+func (s *stateStore) read() (State, bool, error) {
+	state := State{LastFired: make(map[string]int64)}
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return state, false, err
+	}
+	var b []byte
+	switch u.Scheme {
+	case "s3":
+		b, err = getS3Object(s.sess, u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "file", "":
+		b, err = ioutil.ReadFile(u.Path)
+		if os.IsNotExist(err) {
+			b, err = nil, nil
+		}
+	default:
+		err = errors.Errorf("URL scheme %s is not supported", u.Scheme)
+	}
+	if err != nil {
+		return state, false, errors.Wrapf(err, "failed to load state from %s", s.url)
+	}
+	if len(b) == 0 {
+		return state, false, nil
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, false, errors.Wrapf(err, "failed to parse state %s", s.url)
+	}
+	if state.LastFired == nil {
+		state.LastFired = make(map[string]int64)
+	}
+	return state, true, nil
+}
+
+// Save merges the state in the store, and saves the merged state when modified.
+// Paused entries in the store are overwritten only by entries paused or resumed in this process,
+// so that entries paused by other processes sharing the store are kept.
+func (s *stateStore) Save() error {
+	return s.save(false)
+}
+
+// SavePaused is the same as Save, but saves the state only when paused entries are modified.
+// A follower of leader election saves the state by this, not to save the last fired times.
+func (s *stateStore) SavePaused() error {
+	return s.save(true)
+}
+
+func (s *stateStore) save(pausedOnly bool) error {
+	stored, _, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for key, paused := range s.pending {
+		setPaused(&stored, key, paused)
+	}
+	// the last fired times never go back, even if this process has stale ones
+	for key, ts := range s.state.LastFired {
+		if ts > stored.LastFired[key] {
+			stored.LastFired[key] = ts
+		}
+	}
+	s.state = stored
+	pending := s.pending
+	if !s.dirty || pausedOnly && len(pending) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	b, err := json.Marshal(s.state)
+	s.dirty, s.pending = false, nil
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := s.write(b); err != nil {
+		s.mu.Lock()
+		s.dirty = true // retry on next save
+		for key, paused := range pending {
+			if _, ok := s.pending[key]; !ok {
+				s.setPending(key, paused)
+			}
+		}
+		s.mu.Unlock()
+		return err
+	}
+	log.Printf("[debug] state saved to %s", s.url)
+	return nil
+}
+
+func (s *stateStore) write(b []byte) error {
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "s3":
+		err = putS3Object(s.sess, u.Host, strings.TrimPrefix(u.Path, "/"), b)
+	case "file", "":
+		err = writeFileAtomic(u.Path, b)
+	default:
+		err = errors.Errorf("URL scheme %s is not supported", u.Scheme)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to save state to %s", s.url)
+	}
+	return nil
+}
+
+// LastFired returns the last fired time of the entry.
+func (s *stateStore) LastFired(key string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, ok := s.state.LastFired[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// SetLastFired records the last fired time of the entry.
+func (s *stateStore) SetLastFired(key string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Unix() <= s.state.LastFired[key] {
+		return
+	}
+	s.state.LastFired[key] = t.Unix()
+	s.dirty = true
+}
+
+// PausedEntries returns names (or stable IDs) of paused entries.
+func (s *stateStore) PausedEntries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key, paused := range s.state.Paused {
+		if paused {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// SetPaused records whether the entry is paused.
+func (s *stateStore) SetPaused(key string, paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	setPaused(&s.state, key, paused)
+	s.setPending(key, paused)
+	s.dirty = true
+}
+
+func (s *stateStore) setPending(key string, paused bool) {
+	if s.pending == nil {
+		s.pending = make(map[string]bool)
+	}
+	s.pending[key] = paused
+}
+
+func setPaused(state *State, key string, paused bool) {
+	if state.Paused == nil {
+		state.Paused = make(map[string]bool)
+	}
+	if paused {
+		state.Paused[key] = true
+	} else {
+		delete(state.Paused, key)
+	}
+}
+
+func (app *App) flushState() {
+	ticker := time.NewTicker(StateFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		app.syncState()
+	}
+}
+
+// syncState saves the state, and follows entries paused or resumed by other processes sharing the state.
+func (app *App) syncState() {
+	if err := app.saveState(); err != nil {
+		log.Println("[warn]", err)
+		return
+	}
+	app.applyPausedState()
+}
+
+// saveState saves the state. A follower of leader election does not save the last fired times.
+func (app *App) saveState() error {
+	if app.lease != nil && !app.stats.Leader.isLeader() {
+		return app.state.SavePaused()
+	}
+	return app.state.Save()
+}
+
+// catchUp invokes jobs which were missed during the downtime.
+func (app *App) catchUp(now time.Time) {
+	for _, entry := range app.cron.Entries() {
+		j, ok := entry.Job.(*Job)
+		if !ok {
+			continue
+		}
+		last, ok := app.state.LastFired(j.String())
+		if !ok {
+			// new entry. nothing to catch up
+			app.state.SetLastFired(j.String(), now)
+			continue
+		}
+		missed := missedTimes(entry.Schedule, last.In(j.Location), now, app.option.CatchUp, app.option.CatchUpMax)
+		if len(missed) == 0 {
+			continue
+		}
+		log.Printf("[info] [entry:%s] catching up %d missed invocations since %s", j, len(missed), last)
+		// invokes one by one to send messages in order of the invoked times
+		app.wg.Add(1)
+		go func(j *Job, missed []time.Time) {
+			defer app.wg.Done()
+			for _, t := range missed {
+				j.invoke(t)
+			}
+		}(j, missed)
+	}
+}
+
+// missedTimes returns invocation times between last (exclusive) and now (exclusive) by the policy.
+func missedTimes(s cron.Schedule, last, now time.Time, policy string, max int) []time.Time {
+	if policy != CatchUpOnce && policy != CatchUpAll {
+		return nil
+	}
+	if policy == CatchUpOnce {
+		max = 1
+	}
+	if max <= 0 {
+		return nil
+	}
+	var missed []time.Time
+	for t := s.Next(last); !t.IsZero() && t.Before(now); t = s.Next(t) {
+		missed = append(missed, t)
+		if len(missed) > max {
+			missed = missed[1:] // keeps the latest ones
+		}
+	}
+	return missed
+}
+
+func getS3Object(sess *session.Session, bucket, key string) ([]byte, error) {
+	src, err := readS3(sess, bucket, key)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer src.Close()
+	return ioutil.ReadAll(src)
+}
+
+func putS3Object(sess *session.Session, bucket, key string, b []byte) error {
+	svc := s3.New(sess)
+	log.Printf("[debug] writing S3 bucket:%s key:%s", bucket, key)
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(b),
+	})
+	return err
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
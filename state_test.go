@@ -0,0 +1,252 @@
+package sqsjfr_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+	"github.com/robfig/cron/v3"
+)
+
+func TestMissedTimes(t *testing.T) {
+	s, err := cron.ParseStandard("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := time.Date(2020, 10, 7, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2020, 10, 7, 14, 30, 0, 0, time.UTC)
+
+	if m := sqsjfr.MissedTimes(s, last, now, sqsjfr.CatchUpNone, 10); len(m) != 0 {
+		t.Errorf("unexpected missed times by none %v", m)
+	}
+	if m := sqsjfr.MissedTimes(s, last, now, sqsjfr.CatchUpOnce, 10); len(m) != 1 || m[0].Hour() != 14 {
+		t.Errorf("unexpected missed times by once %v", m)
+	}
+	m := sqsjfr.MissedTimes(s, last, now, sqsjfr.CatchUpAll, 10)
+	if len(m) != 4 || m[0].Hour() != 11 || m[3].Hour() != 14 {
+		t.Errorf("unexpected missed times by all %v", m)
+	}
+	m = sqsjfr.MissedTimes(s, last, now, sqsjfr.CatchUpAll, 2)
+	if len(m) != 2 || m[0].Hour() != 13 || m[1].Hour() != 14 {
+		t.Errorf("unexpected missed times by all up to 2 %v", m)
+	}
+	if m := sqsjfr.MissedTimes(s, now, now.Add(time.Minute), sqsjfr.CatchUpAll, 10); len(m) != 0 {
+		t.Errorf("unexpected missed times %v", m)
+	}
+}
+
+func TestStateStoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqsjfr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	s := sqsjfr.NewStateStore(path, nil)
+	if err := s.Load(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := s.LastFired("foo"); ok {
+		t.Error("foo must not be fired yet")
+	}
+	now := time.Unix(1602646620, 0)
+	s.SetLastFired("foo", now)
+	s.SetLastFired("foo", now.Add(-time.Minute)) // older time is ignored
+	if err := s.Save(); err != nil {
+		t.Error(err)
+	}
+
+	s2 := sqsjfr.NewStateStore(path, nil)
+	if err := s2.Load(); err != nil {
+		t.Error(err)
+	}
+	if last, ok := s2.LastFired("foo"); !ok || !last.Equal(now) {
+		t.Errorf("unexpected last fired %s", last)
+	}
+}
+
+func TestStateStoreSavePaused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Unix(1602646620, 0)
+
+	leader := sqsjfr.NewStateStore(path, nil)
+	follower := sqsjfr.NewStateStore(path, nil)
+	if err := follower.Load(); err != nil {
+		t.Fatal(err)
+	}
+	follower.SetLastFired("foo", now.Add(-time.Hour)) // stale
+	leader.SetLastFired("foo", now)
+	if err := leader.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	follower.SetPaused("foo", true)
+	if err := follower.SavePaused(); err != nil {
+		t.Fatal(err)
+	}
+	if ts, _ := follower.LastFired("foo"); !ts.Equal(now) {
+		t.Errorf("follower must follow the last fired time in the store %s", ts)
+	}
+
+	s := sqsjfr.NewStateStore(path, nil)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if ts, _ := s.LastFired("foo"); !ts.Equal(now) {
+		t.Errorf("last fired time must not be overwritten by the follower %s", ts)
+	}
+	if p := s.PausedEntries(); len(p) != 1 || p[0] != "foo" {
+		t.Errorf("unexpected paused entries %v", p)
+	}
+}
+
+func TestPauseSharedByState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	newApp := func() *sqsjfr.App {
+		app := sqsjfr.NewTestApp(&sqsjfr.Option{
+			CrontabURL: "tests/crontab.names",
+			QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+			StateURL:   path,
+			AdminToken: "secret",
+		})
+		app.SetStateURL(path)
+		if err := app.Load(); err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+	app1, app2 := newApp(), newApp()
+	admin := httptest.NewServer(http.HandlerFunc(app1.ServeAdmin))
+	defer admin.Close()
+
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "pause"); err != nil {
+		t.Fatal(err)
+	}
+	app1.SyncState()
+	app2.SyncState()
+	if v := app2.EntryViews()["hourly-sync"]; !v.Paused {
+		t.Error("entry paused by app1 must be paused on app2")
+	}
+	// app2 saves its own state without resuming the entry
+	app2.State().SetLastFired("nightly-report", time.Now())
+	app2.SyncState()
+	app1.SyncState()
+	if v := app1.EntryViews()["hourly-sync"]; !v.Paused {
+		t.Error("entry must be kept paused on app1")
+	}
+
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "resume"); err != nil {
+		t.Fatal(err)
+	}
+	app1.SyncState()
+	app2.SyncState()
+	if v := app2.EntryViews()["hourly-sync"]; v.Paused {
+		t.Error("entry resumed by app1 must be resumed on app2")
+	}
+}
+
+func TestCatchUpAfterPauseResume(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	newApp := func() *sqsjfr.App {
+		app := sqsjfr.NewTestApp(&sqsjfr.Option{
+			CrontabURL: "tests/crontab.names",
+			QueueURL:   ts.URL + "/123456789012/test.fifo",
+			Timezone:   "UTC",
+			StateURL:   path,
+			CatchUp:    sqsjfr.CatchUpAll,
+			CatchUpMax: 10,
+			AdminToken: "secret",
+		})
+		app.SetSQSEndpoint(ts.URL)
+		app.SetStateURL(path)
+		if err := app.State().Load(); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.Load(); err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+	findJob := func(app *sqsjfr.App) *sqsjfr.Job {
+		for _, entry := range app.Entries() {
+			if j := entry.Job.(*sqsjfr.Job); j.Name == "hourly-sync" {
+				return j
+			}
+		}
+		t.Fatal("hourly-sync is not found")
+		return nil
+	}
+
+	app := newApp()
+	admin := httptest.NewServer(http.HandlerFunc(app.ServeAdmin))
+	defer admin.Close()
+	job := findJob(app)
+	base := time.Date(2020, 10, 14, 10, 0, 0, 0, time.UTC)
+	job.Invoke(base)
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "pause"); err != nil {
+		t.Fatal(err)
+	}
+	job.Invoke(base.Add(time.Hour))
+	job.Invoke(base.Add(2 * time.Hour))
+	if _, err := sqsjfr.RequestAdmin(admin.URL, "secret", "hourly-sync", "resume"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State().Save(); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.received) != 1 {
+		t.Fatalf("unexpected received len %d", len(f.received))
+	}
+
+	// restarts. invocations skipped by paused must not be caught up
+	app2 := newApp()
+	app2.CatchUp(base.Add(2*time.Hour + 30*time.Minute))
+	if len(f.received) != 1 {
+		t.Errorf("skipped invocations are caught up %v", f.received[1:])
+	}
+}
+
+func TestCatchUpInOrder(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   ts.URL + "/123456789012/test.fifo",
+		Timezone:   "UTC",
+		StateURL:   path,
+		CatchUp:    sqsjfr.CatchUpAll,
+		CatchUpMax: 10,
+	})
+	app.SetSQSEndpoint(ts.URL)
+	app.SetStateURL(path)
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2020, 10, 14, 10, 0, 0, 0, time.UTC)
+	app.State().SetLastFired("hourly-sync", base)
+	app.CatchUp(base.Add(5*time.Hour + 30*time.Minute))
+	if len(f.received) != 5 {
+		t.Fatalf("unexpected received len %d", len(f.received))
+	}
+	for i, m := range f.received {
+		var msg sqsjfr.Message
+		if err := json.Unmarshal([]byte(m["MessageBody"]), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if expected := base.Add(time.Duration(i+1) * time.Hour).Unix(); msg.InvokedAt != expected {
+			t.Errorf("unexpected invoked_at of message %d: %d expected %d", i, msg.InvokedAt, expected)
+		}
+	}
+}
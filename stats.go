@@ -6,6 +6,10 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Stats represents sqsjfr stats.
@@ -14,9 +18,213 @@ type Stats struct {
 		Registered int64 `json:"registered"`
 	} `json:"entries"`
 	Invocations struct {
-		Succeeded int64 `json:"succeeded"`
-		Failed    int64 `json:"failed"`
+		Succeeded    int64 `json:"succeeded"`
+		Failed       int64 `json:"failed"`
+		Retried      int64 `json:"retried"`
+		Skipped      int64 `json:"skipped"`
+		Deduplicated int64 `json:"deduplicated"`
 	} `json:"invocations"`
+	Spool struct {
+		Depth   int64 `json:"depth"`
+		Resent  int64 `json:"resent"`
+		Dropped int64 `json:"dropped"`
+	} `json:"spool"`
+	Crontab struct {
+		Reloads int64 `json:"reloads"`
+	} `json:"crontab"`
+	Leader *LeaderStats `json:"leader,omitempty"`
+
+	mu          sync.Mutex
+	entries     map[string]*EntryStats
+	sendLatency histogram
+}
+
+// EntryStats represents stats of an entry.
+type EntryStats struct {
+	Succeeded     int64 `json:"succeeded"`
+	Failed        int64 `json:"failed"`
+	Retried       int64 `json:"retried"`
+	Skipped       int64 `json:"skipped"`
+	Deduplicated  int64 `json:"deduplicated"`
+	FailedRecords int64 `json:"failed_records"` // records failed in PutRecords requests to Kinesis
+
+	mu            sync.Mutex
+	lastInvokedAt time.Time
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// EntryView represents a registered entry and its stats.
+type EntryView struct {
+	Name          string     `json:"name,omitempty"`
+	StableID      string     `json:"stable_id"`
+	Spec          string     `json:"spec"`
+	Timezone      string     `json:"timezone"`
+	Command       string     `json:"command"`
+	Paused        bool       `json:"paused"`
+	Next          *time.Time `json:"next,omitempty"`
+	LastInvokedAt *time.Time `json:"last_invoked_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	Succeeded     int64      `json:"succeeded"`
+	Failed        int64      `json:"failed"`
+	Retried       int64      `json:"retried"`
+	Skipped       int64      `json:"skipped"`
+	Deduplicated  int64      `json:"deduplicated"`
+	FailedRecords int64      `json:"failed_records"`
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// entry returns stats of the entry identified by the key.
+func (s *Stats) entry(key string) *EntryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]*EntryStats)
+	}
+	e, ok := s.entries[key]
+	if !ok {
+		e = &EntryStats{}
+		s.entries[key] = e
+	}
+	return e
+}
+
+// entrySnapshot returns sorted keys and stats of the entries.
+func (s *Stats) entrySnapshot() ([]string, map[string]*EntryStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.entries))
+	entries := make(map[string]*EntryStats, len(s.entries))
+	for key, e := range s.entries {
+		keys = append(keys, key)
+		entries[key] = e
+	}
+	sort.Strings(keys)
+	return keys, entries
+}
+
+// removeEntry removes stats of the entry which is removed from crontab.
+func (s *Stats) removeEntry(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+func (s *Stats) countInvocation(key string, invokedAt time.Time, err error) {
+	e := s.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if invokedAt.After(e.lastInvokedAt) {
+		e.lastInvokedAt = invokedAt
+	}
+	if err != nil {
+		atomic.AddInt64(&s.Invocations.Failed, 1)
+		atomic.AddInt64(&e.Failed, 1)
+		e.setError(err)
+	} else {
+		atomic.AddInt64(&s.Invocations.Succeeded, 1)
+		atomic.AddInt64(&e.Succeeded, 1)
+	}
+}
+
+// countResend counts an attempt to resend a spooled message as a retry.
+// The invocation was counted as failed already, so a failure only updates the last error.
+func (s *Stats) countResend(key string, invokedAt time.Time, err error) {
+	atomic.AddInt64(&s.Invocations.Retried, 1)
+	atomic.AddInt64(&s.entry(key).Retried, 1)
+	if err == nil {
+		s.countInvocation(key, invokedAt, nil)
+		return
+	}
+	e := s.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.setError(err)
+}
+
+func (e *EntryStats) setError(err error) {
+	e.lastError = err.Error()
+	e.lastErrorAt = time.Now()
+}
+
+// entryViews returns views of the registered entries keyed by the names (or stable IDs).
+func (app *App) entryViews() map[string]*EntryView {
+	views := make(map[string]*EntryView)
+	if app.cron == nil {
+		return views // not loaded yet
+	}
+	for _, entry := range app.cron.Entries() {
+		j, ok := entry.Job.(*Job)
+		if !ok {
+			continue
+		}
+		key := j.String()
+		if _, exists := views[key]; exists {
+			continue // identical entries
+		}
+		e := app.stats.entry(key)
+		app.pausedMu.RLock()
+		paused := app.paused[key]
+		app.pausedMu.RUnlock()
+		e.mu.Lock()
+		views[key] = &EntryView{
+			Name:          j.Name,
+			StableID:      j.StableID,
+			Spec:          j.Spec,
+			Timezone:      j.Location.String(),
+			Command:       j.Command,
+			Paused:        paused,
+			Next:          timePtr(entry.Next),
+			LastInvokedAt: timePtr(e.lastInvokedAt),
+			LastError:     e.lastError,
+			LastErrorAt:   timePtr(e.lastErrorAt),
+			Succeeded:     atomic.LoadInt64(&e.Succeeded),
+			Failed:        atomic.LoadInt64(&e.Failed),
+			Retried:       atomic.LoadInt64(&e.Retried),
+			Skipped:       atomic.LoadInt64(&e.Skipped),
+			Deduplicated:  atomic.LoadInt64(&e.Deduplicated),
+			FailedRecords: atomic.LoadInt64(&e.FailedRecords),
+		}
+		e.mu.Unlock()
+	}
+	return views
+}
+
+// latencyBuckets defines upper bounds of buckets of send latency in seconds.
+var latencyBuckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram represents a histogram of durations.
+type histogram struct {
+	mu     sync.Mutex
+	counts [len(latencyBuckets)]uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, le := range latencyBuckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) snapshot() (counts [len(latencyBuckets)]uint64, count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.counts, h.count, h.sum
 }
 
 func (app *App) runStatsServer() error {
@@ -27,8 +235,18 @@ func (app *App) runStatsServer() error {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}
+	entriesHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(app.entryViews()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/stats/metrics", handler)
+	mux.HandleFunc("/stats/entries", entriesHandler)
+	mux.HandleFunc("/entries/", app.serveAdmin)
+	mux.HandleFunc("/metrics", app.serveMetrics)
 	addr := fmt.Sprintf(":%d", app.option.StatsPort)
 	srv := &http.Server{
 		Handler: mux,
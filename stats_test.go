@@ -0,0 +1,61 @@
+package sqsjfr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kayac/sqsjfr"
+)
+
+func TestEntryViews(t *testing.T) {
+	f, ts := newFakeSQS()
+	defer ts.Close()
+	f.errors = []string{"AccessDenied"}
+
+	app := sqsjfr.NewTestApp(&sqsjfr.Option{
+		CrontabURL: "tests/crontab.names",
+		QueueURL:   "https://sqs.ap-northeast-1.amazonaws.com/123456789012/test.fifo",
+		Timezone:   "UTC",
+	})
+	app.SetSQSEndpoint(ts.URL)
+	if err := app.Load(); err != nil {
+		t.Fatal(err)
+	}
+	var job *sqsjfr.Job
+	for _, entry := range app.Entries() {
+		if j := entry.Job.(*sqsjfr.Job); j.Name == "nightly-report" {
+			job = j
+		}
+	}
+	invokedAt := time.Date(2020, 10, 7, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		msg, err := app.NewMessage(job, invokedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.Send(msg)
+	}
+
+	views := app.EntryViews()
+	if len(views) != 3 {
+		t.Errorf("unexpected views len %d", len(views))
+	}
+	v, ok := views["nightly-report"]
+	if !ok {
+		t.Fatal("nightly-report is not found")
+	}
+	if v.Spec != "0 3 * * *" || v.Command != "echo report" || v.Timezone != "UTC" || v.StableID != job.StableID {
+		t.Errorf("unexpected view %#v", v)
+	}
+	if v.Succeeded != 1 || v.Failed != 1 || v.LastError == "" {
+		t.Errorf("unexpected stats %#v", v)
+	}
+	if v.LastInvokedAt == nil || !v.LastInvokedAt.Equal(invokedAt) {
+		t.Errorf("unexpected last invoked at %v", v.LastInvokedAt)
+	}
+	for key, v := range views {
+		if v.Succeeded+v.Failed == 0 && v.LastInvokedAt != nil {
+			t.Errorf("unexpected last invoked at of %s", key)
+		}
+	}
+}